@@ -1,29 +1,50 @@
 package main
 
 import (
+	"cmp"
+	"context"
+	"errors"
 	"fmt"
 	"iter"
+	"log/slog"
+	"maps"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/canonical/jira-summarizer/internal/jira"
 )
 
+// backend retrieves top issues and posts summaries on them.
+// It is implemented by the Jira client, and by snapshots which are read only.
+type backend interface {
+	GetMyAssignedEpics(ctx context.Context) iter.Seq2[jira.Issue, error]
+	GetIssuesByKeys(ctx context.Context, keys ...string) iter.Seq2[jira.Issue, error]
+	AddComment(ctx context.Context, key, commentBody string) error
+}
+
 // getTopIssues returns top issues from Jira based on provided keys and grouping strategy.
 // It defaults to assigned epics.
-func getTopIssues(jc *jira.Client, groupStrategy string, topIssueKeys ...string) iter.Seq2[jira.Issue, error] {
+// Issue keys which can't be retrieved are skipped with a warning, unless strict is set.
+func getTopIssues(ctx context.Context, source backend, groupStrategy string, strict bool, topIssueKeys ...string) iter.Seq2[jira.Issue, error] {
 	return func(yield func(jira.Issue, error) bool) {
 
-		topIssuersFunc := jc.GetMyAssignedEpics
+		topIssuersFunc := source.GetMyAssignedEpics
 		if len(topIssueKeys) > 0 {
-			topIssuersFunc = func() iter.Seq2[jira.Issue, error] {
-				return jc.GetIssuesByKeys(topIssueKeys...)
+			topIssuersFunc = func(ctx context.Context) iter.Seq2[jira.Issue, error] {
+				return source.GetIssuesByKeys(ctx, topIssueKeys...)
 			}
 		}
 
 		var mergedTopIssues []jira.Issue
-		for issue, err := range topIssuersFunc() {
+		for issue, err := range topIssuersFunc(ctx) {
+			var keyErr *jira.KeyError
+			if !strict && errors.As(err, &keyErr) {
+				slog.Warn(fmt.Sprintf("skipping %v", keyErr))
+				continue
+			}
 			if err != nil {
-				yield(jira.Issue{}, fmt.Errorf("error fetching issues: %v", err))
+				yield(jira.Issue{}, fmt.Errorf("error fetching issues: %w", err))
 				return
 			}
 
@@ -58,8 +79,26 @@ func getTopIssues(jc *jira.Client, groupStrategy string, topIssueKeys ...string)
 	}
 }
 
-// report generates a formatted string representation of the issue, including its children.
-func report(topIssue jira.Issue) string {
+// topIssueReport returns the report of the top issue with only its events since sinceTime, or false if nothing
+// happened on it nor its descendants since then.
+// Open blockers are collected beforehand, so that those of idle descendants are still called out.
+func topIssueReport(issue jira.Issue, sinceTime time.Time, changes jira.ChangeFilter) (string, bool) {
+	if issue.Embedder() {
+		// Don't show comments on top issues which are embedder, as they can be generated from children work.
+		issue.Comments = nil
+	}
+
+	blockers := openBlockers(issue)
+	if !issue.KeptRecentEvents(sinceTime, changes) {
+		return "", false
+	}
+
+	return report(issue, blockers), true
+}
+
+// report generates a formatted string representation of the issue, including its children, calling out the
+// given open blockers.
+func report(topIssue jira.Issue, blockers []string) string {
 	var r strings.Builder
 	if topIssue.Embedder() {
 		r.WriteString("< This top issue is tracking all children work here")
@@ -69,7 +108,72 @@ func report(topIssue jira.Issue) string {
 		r.WriteString(". >\n")
 	}
 
+	for _, blocker := range blockers {
+		r.WriteString(fmt.Sprintf("< Open blocker: %s >\n", blocker))
+	}
+
+	r.WriteString(timeSpentReport(topIssue))
+
 	r.WriteString(topIssue.Format(false))
 
 	return strings.TrimRight(r.String(), "| \n")
 }
+
+// openBlockers returns the descriptions of the issues blocking the issue or any of its descendants which
+// are not done yet. Issues which are done themselves are not blocked anymore.
+func openBlockers(issue jira.Issue) []string {
+	var blockers []string
+	if !issue.Done {
+		for _, l := range issue.OpenBlockers() {
+			blockers = append(blockers, fmt.Sprintf("%s %s %s (%s): %s", issue.Key, l.Relation, l.Key, l.Status, l.Summary))
+		}
+	}
+	for _, child := range issue.Children {
+		blockers = append(blockers, openBlockers(child)...)
+	}
+	return blockers
+}
+
+// timeSpentReport returns the time logged on the issue and its descendants, in total, per direct child and
+// per person. It is empty when no time was logged.
+func timeSpentReport(issue jira.Issue) string {
+	total := issue.TimeSpent()
+	if total == 0 {
+		return ""
+	}
+
+	var r strings.Builder
+	r.WriteString(fmt.Sprintf("< Time logged in the period: %s >\n", jira.FormatTimeSpent(total)))
+
+	if len(issue.Children) > 0 {
+		var perTask []string
+		var own time.Duration
+		for _, w := range issue.Worklogs {
+			own += w.TimeSpent
+		}
+		if own > 0 {
+			perTask = append(perTask, fmt.Sprintf("%s %s", issue.Key, jira.FormatTimeSpent(own)))
+		}
+		for _, child := range issue.Children {
+			if spent := child.TimeSpent(); spent > 0 {
+				perTask = append(perTask, fmt.Sprintf("%s %s", child.Key, jira.FormatTimeSpent(spent)))
+			}
+		}
+		r.WriteString(fmt.Sprintf("< Time logged per task: %s >\n", strings.Join(perTask, ", ")))
+	}
+
+	perPerson := issue.TimeSpentPerPerson()
+	people := slices.SortedFunc(maps.Keys(perPerson), func(a, b string) int {
+		if c := cmp.Compare(perPerson[b], perPerson[a]); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	})
+	var perPersonText []string
+	for _, who := range people {
+		perPersonText = append(perPersonText, fmt.Sprintf("%s %s", who, jira.FormatTimeSpent(perPerson[who])))
+	}
+	r.WriteString(fmt.Sprintf("< Time logged per person: %s >\n", strings.Join(perPersonText, ", ")))
+
+	return r.String()
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"maps"
+	"testing"
+	"time"
+
+	"github.com/canonical/jira-summarizer/internal/jira"
+	"github.com/canonical/jira-summarizer/internal/testutils"
+)
+
+func TestReport(t *testing.T) {
+	t.Parallel()
+
+	// Issues are rendered like in the jira package, with report specific elements on top.
+	tests := testutils.IssueCases()
+	maps.Copy(tests, map[string]jira.Issue{
+		"Open blockers": testutils.WithChildren(func() jira.Issue {
+			i := testutils.NewIssue("EPIC-1", "Epic")
+			i.Links = []jira.Link{{Relation: "is blocked by", Key: "OPS-1", Summary: "Provision servers", Status: "In Progress"}}
+			return i
+		}(), func() jira.Issue {
+			i := testutils.NewIssue("TASK-1", "Task")
+			i.Links = []jira.Link{
+				{Relation: "is blocked by", Key: "OPS-2", Summary: "Open firewall", Status: "To Do"},
+				{Relation: "is blocked by", Key: "OPS-3", Summary: "Buy licenses", Status: "Done", Done: true},
+				{Relation: "relates to", Key: "OPS-4", Summary: "Related work", Status: "To Do"},
+			}
+			return i
+		}()),
+		"Time spent": testutils.WithChildren(func() jira.Issue {
+			i := testutils.NewIssue("EPIC-1", "Epic")
+			i.Worklogs = []jira.Worklog{{Who: "Bob", Started: day, TimeSpent: 30 * time.Minute}}
+			return i
+		}(), func() jira.Issue {
+			i := testutils.NewIssue("TASK-1", "Task")
+			i.Worklogs = []jira.Worklog{
+				{Who: "Alice", Started: day, TimeSpent: 2 * time.Hour, Comment: "Investigation"},
+				{Who: "Bob", Started: day.Add(2 * time.Hour), TimeSpent: time.Hour},
+			}
+			return i
+		}(), testutils.WithChildren(testutils.NewIssue("TASK-2", "Task"), func() jira.Issue {
+			i := testutils.NewIssue("SUB-1", "Sub-task")
+			i.Worklogs = []jira.Worklog{{Who: "Carol", Started: day, TimeSpent: 90 * time.Minute}}
+			return i
+		}()), testutils.NewIssue("TASK-3", "Task")),
+	})
+	for name, issue := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			testutils.CheckOrUpdateGolden(t, report(issue, openBlockers(issue)))
+		})
+	}
+}
+
+func TestTopIssueReport(t *testing.T) {
+	t.Parallel()
+
+	since := day.AddDate(0, 0, -7)
+	old := day.AddDate(0, -1, 0)
+	idle := func(key, issueType string) jira.Issue {
+		return jira.Issue{Key: key, IssueType: issueType, Summary: "Summary of " + key, Created: old}
+	}
+
+	tests := map[string]struct {
+		issue jira.Issue
+
+		wantSkipped bool
+	}{
+		"Open blockers of idle children": {issue: testutils.WithChildren(testutils.NewIssue("EPIC-1", "Epic"),
+			testutils.NewIssue("TASK-1", "Task"),
+			testutils.WithChildren(idle("TASK-2", "Task"), func() jira.Issue {
+				i := idle("SUB-1", "Sub-task")
+				i.Links = []jira.Link{{Relation: "is blocked by", Key: "OPS-1", Summary: "Provision servers", Status: "In Progress"}}
+				return i
+			}()),
+		)},
+
+		"No open blockers of done children": {issue: testutils.WithChildren(testutils.NewIssue("EPIC-1", "Epic"),
+			func() jira.Issue {
+				i := testutils.NewIssue("TASK-1", "Task")
+				i.Resolution, i.ResolutionDate, i.Done = "Done", day, true
+				i.Changes = []jira.Change{{Field: "resolution", To: "Done", Who: "Bob", When: day}}
+				i.Links = []jira.Link{{Relation: "is blocked by", Key: "OPS-1", Summary: "Provision servers", Status: "In Progress"}}
+				return i
+			}(),
+			func() jira.Issue {
+				i := testutils.NewIssue("TASK-2", "Task")
+				i.Links = []jira.Link{{Relation: "is blocked by", Key: "OPS-2", Summary: "Open firewall", Status: "To Do"}}
+				return i
+			}(),
+		)},
+
+		"Skips idle top issues even with open blockers": {issue: func() jira.Issue {
+			i := idle("EPIC-1", "Epic")
+			i.Links = []jira.Link{{Relation: "is blocked by", Key: "OPS-1", Summary: "Provision servers", Status: "In Progress"}}
+			return i
+		}(), wantSkipped: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, ok := topIssueReport(tc.issue, since, jira.ChangeFilter{Activity: jira.DefaultChangeFields, Shown: jira.DefaultChangeFields})
+			if tc.wantSkipped {
+				if ok {
+					t.Fatalf("topIssueReport should have skipped the issue, got:\n%s", got)
+				}
+				return
+			}
+			if !ok {
+				t.Fatal("topIssueReport skipped the issue, want a report")
+			}
+
+			testutils.CheckOrUpdateGolden(t, got)
+		})
+	}
+}
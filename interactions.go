@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"log/slog"
 	"os"
@@ -23,7 +24,7 @@ const editableSeparator = "<----- ANY CONTENTS BELOW THIS WILL BE IGNORED ----->
 
 // editSummaryAndPost opens the editor with the provided issue summary and allows the user to edit it.
 // If the user empty the content or does not change it, it will ask if they want to skip posting.
-func editSummaryAndPost(jiraClient *jira.Client, issue jira.Issue, summary string) error {
+func editSummaryAndPost(ctx context.Context, b backend, issue jira.Issue, summary string) error {
 	summary = fmt.Sprintf("\n\n%s\n\n%s", editableSeparator, summary)
 	for {
 		edited, err := openInEditor(summary)
@@ -41,7 +42,7 @@ func editSummaryAndPost(jiraClient *jira.Client, issue jira.Issue, summary strin
 			return nil
 		}
 
-		if err := issue.AddComment(jiraClient, edited); err != nil {
+		if err := b.AddComment(ctx, issue.Key, edited); err != nil {
 			return err
 		}
 
@@ -0,0 +1,358 @@
+// Package adf converts between Atlassian Document Format and Markdown.
+//
+// ADF is the JSON rich text format used by the Jira Cloud v3 REST API for descriptions and comments.
+package adf
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Node is an Atlassian Document Format node.
+type Node struct {
+	Type    string         `json:"type"`
+	Version int            `json:"version,omitempty"`
+	Attrs   map[string]any `json:"attrs,omitempty"`
+	Content []Node         `json:"content,omitempty"`
+	Text    string         `json:"text,omitempty"`
+	Marks   []Mark         `json:"marks,omitempty"`
+}
+
+// Mark is a formatting applied to a text node.
+type Mark struct {
+	Type  string         `json:"type"`
+	Attrs map[string]any `json:"attrs,omitempty"`
+}
+
+// TextOrMarkdown converts a raw JSON field holding either a plain string (v2 API) or an ADF document
+// (v3 API) to Markdown.
+func TextOrMarkdown(raw json.RawMessage) (string, error) {
+	raw = json.RawMessage(strings.TrimSpace(string(raw)))
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", nil
+	}
+
+	if raw[0] == '"' {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return "", err
+		}
+		return s, nil
+	}
+
+	var doc Node
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return "", fmt.Errorf("invalid ADF document: %v", err)
+	}
+	return ToMarkdown(doc), nil
+}
+
+// ToMarkdown renders an ADF document as Markdown.
+func ToMarkdown(doc Node) string {
+	var sb strings.Builder
+	writeBlocks(&sb, doc.Content, "")
+	return strings.TrimSpace(sb.String())
+}
+
+// writeBlocks renders block nodes separated by empty lines, each line starting with prefix.
+func writeBlocks(sb *strings.Builder, nodes []Node, prefix string) {
+	for i, n := range nodes {
+		if i > 0 {
+			sb.WriteString(strings.TrimRight(prefix, " ") + "\n")
+		}
+		writeBlock(sb, n, prefix)
+	}
+}
+
+// writeBlock renders a single block node, each line starting with prefix.
+func writeBlock(sb *strings.Builder, n Node, prefix string) {
+	writeLines := func(s string) {
+		for _, line := range strings.Split(s, "\n") {
+			sb.WriteString(strings.TrimRight(prefix+line, " ") + "\n")
+		}
+	}
+
+	switch n.Type {
+	case "paragraph":
+		writeLines(inline(n.Content))
+
+	case "heading":
+		level := min(max(attrInt(n, "level"), 1), 6)
+		writeLines(strings.Repeat("#", level) + " " + inline(n.Content))
+
+	case "bulletList", "orderedList", "taskList", "decisionList":
+		writeList(sb, n, prefix)
+
+	case "codeBlock":
+		var code strings.Builder
+		for _, t := range n.Content {
+			code.WriteString(t.Text)
+		}
+		writeLines("```" + attrString(n, "language") + "\n" + code.String() + "\n```")
+
+	case "blockquote":
+		writeBlocks(sb, n.Content, prefix+"> ")
+
+	case "panel":
+		title := panelTitle(attrString(n, "panelType"))
+		var content strings.Builder
+		writeBlocks(&content, n.Content, "")
+		writeLines(strings.TrimRight(fmt.Sprintf("> **%s:** %s", title, strings.ReplaceAll(strings.TrimSpace(content.String()), "\n", "\n> ")), " "))
+
+	case "expand", "nestedExpand":
+		if title := attrString(n, "title"); title != "" {
+			writeLines("**" + title + "**")
+			sb.WriteString(strings.TrimRight(prefix, " ") + "\n")
+		}
+		writeBlocks(sb, n.Content, prefix)
+
+	case "rule":
+		writeLines("---")
+
+	case "table":
+		writeLines(table(n))
+
+	case "mediaSingle", "mediaGroup":
+		writeLines("[attachment]")
+
+	case "blockCard", "embedCard":
+		writeLines(attrString(n, "url"))
+
+	default:
+		// Unknown blocks or inline nodes at block level: render what we can.
+		if len(n.Content) > 0 && isBlock(n.Content[0]) {
+			writeBlocks(sb, n.Content, prefix)
+			return
+		}
+		writeLines(inline([]Node{n}))
+	}
+}
+
+// writeList renders a list and its nested lists.
+func writeList(sb *strings.Builder, list Node, prefix string) {
+	order := 1
+	if list.Type == "orderedList" {
+		if o := attrInt(list, "order"); o > 0 {
+			order = o
+		}
+	}
+
+	for _, item := range list.Content {
+		var marker string
+		switch list.Type {
+		case "orderedList":
+			marker = fmt.Sprintf("%d. ", order)
+			order++
+		case "taskList":
+			marker = "- [ ] "
+			if attrString(item, "state") == "DONE" {
+				marker = "- [x] "
+			}
+		case "decisionList":
+			marker = "- ✓ "
+		default:
+			marker = "- "
+		}
+
+		// Task and decision items directly hold inline content.
+		if item.Type == "taskItem" || item.Type == "decisionItem" {
+			sb.WriteString(strings.TrimRight(prefix+marker+inline(item.Content), " ") + "\n")
+			continue
+		}
+
+		var content strings.Builder
+		for i, child := range item.Content {
+			if i > 0 && child.Type == "paragraph" {
+				content.WriteString("\n")
+			}
+			writeBlock(&content, child, "")
+		}
+
+		indent := strings.Repeat(" ", len([]rune(marker)))
+		for i, line := range strings.Split(strings.TrimRight(content.String(), "\n"), "\n") {
+			if i == 0 {
+				sb.WriteString(strings.TrimRight(prefix+marker+line, " ") + "\n")
+				continue
+			}
+			sb.WriteString(strings.TrimRight(prefix+indent+line, " ") + "\n")
+		}
+	}
+}
+
+// table renders an ADF table as a Markdown table. The first row is always used as header.
+func table(n Node) string {
+	var rows [][]string
+	var width int
+	for _, row := range n.Content {
+		var cells []string
+		for _, cell := range row.Content {
+			var content strings.Builder
+			writeBlocks(&content, cell.Content, "")
+			text := strings.TrimSpace(content.String())
+			text = strings.ReplaceAll(text, "\n\n", "<br>")
+			text = strings.ReplaceAll(text, "\n", "<br>")
+			cells = append(cells, strings.ReplaceAll(text, "|", `\|`))
+		}
+		width = max(width, len(cells))
+		rows = append(rows, cells)
+	}
+	if len(rows) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	writeRow := func(cells []string) {
+		for len(cells) < width {
+			cells = append(cells, "")
+		}
+		sb.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	}
+
+	writeRow(rows[0])
+	writeRow(slices.Repeat([]string{"---"}, width))
+	for _, row := range rows[1:] {
+		writeRow(row)
+	}
+
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// inline renders inline nodes as Markdown.
+func inline(nodes []Node) string {
+	var sb strings.Builder
+	for _, n := range nodes {
+		switch n.Type {
+		case "text":
+			sb.WriteString(markText(n.Text, n.Marks))
+		case "hardBreak":
+			sb.WriteString("\n")
+		case "mention":
+			text := attrString(n, "text")
+			if text == "" {
+				text = attrString(n, "id")
+			}
+			if !strings.HasPrefix(text, "@") {
+				text = "@" + text
+			}
+			sb.WriteString(text)
+		case "emoji":
+			if text := attrString(n, "text"); text != "" {
+				sb.WriteString(text)
+				continue
+			}
+			sb.WriteString(attrString(n, "shortName"))
+		case "inlineCard":
+			sb.WriteString(attrString(n, "url"))
+		case "status":
+			sb.WriteString("[" + attrString(n, "text") + "]")
+		case "date":
+			ms, err := strconv.ParseInt(attrString(n, "timestamp"), 10, 64)
+			if err != nil {
+				continue
+			}
+			sb.WriteString(time.UnixMilli(ms).UTC().Format("2006-01-02"))
+		case "media", "mediaInline":
+			sb.WriteString("[attachment]")
+		default:
+			// Block content nested in an inline context, like paragraphs in table cells.
+			if isBlock(n) {
+				var block strings.Builder
+				writeBlock(&block, n, "")
+				sb.WriteString(strings.TrimSpace(block.String()))
+				continue
+			}
+			sb.WriteString(inline(n.Content))
+		}
+	}
+	return sb.String()
+}
+
+// markText applies text marks as Markdown.
+func markText(text string, marks []Mark) string {
+	if text == "" {
+		return ""
+	}
+
+	original := text
+	var link string
+	for _, m := range marks {
+		switch m.Type {
+		case "code":
+			text = "`" + text + "`"
+		case "strong":
+			text = "**" + text + "**"
+		case "em":
+			text = "*" + text + "*"
+		case "strike":
+			text = "~~" + text + "~~"
+		case "link":
+			if href, ok := m.Attrs["href"].(string); ok {
+				link = href
+			}
+		}
+	}
+
+	switch {
+	case link == "":
+	case link == original && text == original:
+		// Bare links are rendered as is.
+	default:
+		text = "[" + text + "](" + link + ")"
+	}
+	return text
+}
+
+// isBlock returns if the node is a block level node.
+func isBlock(n Node) bool {
+	switch n.Type {
+	case "paragraph", "heading", "bulletList", "orderedList", "taskList", "decisionList", "codeBlock",
+		"blockquote", "panel", "expand", "nestedExpand", "rule", "table", "mediaSingle", "mediaGroup",
+		"blockCard", "embedCard":
+		return true
+	}
+	return false
+}
+
+// panelTitle returns the human readable name of an ADF panel type.
+func panelTitle(panelType string) string {
+	switch panelType {
+	case "note":
+		return "Note"
+	case "warning":
+		return "Warning"
+	case "error":
+		return "Error"
+	case "success":
+		return "Success"
+	case "tip":
+		return "Tip"
+	}
+	return "Info"
+}
+
+// attrString returns the attribute of a node as a string, or an empty string if unset.
+func attrString(n Node, key string) string {
+	switch v := n.Attrs[key].(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// attrInt returns the attribute of a node as an integer, or 0 if unset or invalid.
+func attrInt(n Node, key string) int {
+	i, err := strconv.Atoi(attrString(n, key))
+	if err != nil {
+		return 0
+	}
+	return i
+}
@@ -0,0 +1,201 @@
+package adf_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/canonical/jira-summarizer/internal/adf"
+)
+
+func TestTextOrMarkdown(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		raw string
+
+		want    string
+		wantErr bool
+	}{
+		"Plain string":             {raw: `"Plain *text*"`, want: "Plain *text*"},
+		"Null":                     {raw: `null`, want: ""},
+		"Empty":                    {raw: ``, want: ""},
+		"ADF document":             {raw: `{"type":"doc","version":1,"content":[{"type":"paragraph","content":[{"type":"text","text":"Hello"}]}]}`, want: "Hello"},
+		"Error on invalid ADF":     {raw: `{"type":`, wantErr: true},
+		"Error on invalid string":  {raw: `"unterminated`, wantErr: true},
+		"Error on unexpected type": {raw: `{"type":"doc","content":"not a list"}`, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := adf.TextOrMarkdown(json.RawMessage(tc.raw))
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("TextOrMarkdown should have failed but didn't")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("TextOrMarkdown returned an unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("TextOrMarkdown returned %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestToMarkdown(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		content []adf.Node
+
+		want string
+	}{
+		"Paragraphs": {
+			content: []adf.Node{paragraph(text("First")), paragraph(text("Second"), adf.Node{Type: "hardBreak"}, text("line"))},
+			want:    "First\n\nSecond\nline",
+		},
+		"Headings": {
+			content: []adf.Node{
+				{Type: "heading", Attrs: map[string]any{"level": 2.0}, Content: []adf.Node{text("Title")}},
+				{Type: "heading", Attrs: map[string]any{"level": 9.0}, Content: []adf.Node{text("Clamped")}},
+			},
+			want: "## Title\n\n###### Clamped",
+		},
+		"Marks": {
+			content: []adf.Node{paragraph(
+				text("bold", adf.Mark{Type: "strong"}), text(" "),
+				text("italic", adf.Mark{Type: "em"}), text(" "),
+				text("struck", adf.Mark{Type: "strike"}), text(" "),
+				text("code", adf.Mark{Type: "code"}), text(" "),
+				text("link", link("https://example.com")), text(" "),
+				text("https://example.com", link("https://example.com")),
+			)},
+			want: "**bold** *italic* ~~struck~~ `code` [link](https://example.com) https://example.com",
+		},
+		"Bullet list": {
+			content: []adf.Node{list("bulletList", nil, item(paragraph(text("one"))), item(paragraph(text("two"))))},
+			want:    "- one\n- two",
+		},
+		"Ordered list with start": {
+			content: []adf.Node{list("orderedList", map[string]any{"order": 3.0}, item(paragraph(text("three"))), item(paragraph(text("four"))))},
+			want:    "3. three\n4. four",
+		},
+		"Nested lists": {
+			content: []adf.Node{list("bulletList", nil,
+				item(paragraph(text("parent")), list("orderedList", nil, item(paragraph(text("child"))),
+					item(paragraph(text("other child")), list("bulletList", nil, item(paragraph(text("grandchild"))))))),
+				item(paragraph(text("sibling"))),
+			)},
+			want: "- parent\n  1. child\n  2. other child\n     - grandchild\n- sibling",
+		},
+		"Task and decision lists": {
+			content: []adf.Node{
+				{Type: "taskList", Content: []adf.Node{
+					{Type: "taskItem", Attrs: map[string]any{"state": "DONE"}, Content: []adf.Node{text("done")}},
+					{Type: "taskItem", Attrs: map[string]any{"state": "TODO"}, Content: []adf.Node{text("todo")}},
+				}},
+				{Type: "decisionList", Content: []adf.Node{{Type: "decisionItem", Content: []adf.Node{text("decided")}}}},
+			},
+			want: "- [x] done\n- [ ] todo\n\n- ✓ decided",
+		},
+		"Code block": {
+			content: []adf.Node{{Type: "codeBlock", Attrs: map[string]any{"language": "go"}, Content: []adf.Node{text("fmt.Println()\nreturn")}}},
+			want:    "```go\nfmt.Println()\nreturn\n```",
+		},
+		"Blockquote": {
+			content: []adf.Node{{Type: "blockquote", Content: []adf.Node{paragraph(text("quoted")), paragraph(text("more"))}}},
+			want:    "> quoted\n>\n> more",
+		},
+		"Panels": {
+			content: []adf.Node{
+				{Type: "panel", Attrs: map[string]any{"panelType": "warning"}, Content: []adf.Node{paragraph(text("Careful"))}},
+				{Type: "panel", Attrs: map[string]any{"panelType": "custom"}, Content: []adf.Node{paragraph(text("First")), paragraph(text("Second"))}},
+			},
+			want: "> **Warning:** Careful\n\n> **Info:** First\n>\n> Second",
+		},
+		"Expand": {
+			content: []adf.Node{{Type: "expand", Attrs: map[string]any{"title": "Details"}, Content: []adf.Node{paragraph(text("Hidden"))}}},
+			want:    "**Details**\n\nHidden",
+		},
+		"Rule": {
+			content: []adf.Node{paragraph(text("Above")), {Type: "rule"}, paragraph(text("Below"))},
+			want:    "Above\n\n---\n\nBelow",
+		},
+		"Table": {
+			content: []adf.Node{{Type: "table", Content: []adf.Node{
+				row("tableHeader", "Name", "Value"),
+				row("tableCell", "a|b", "1"),
+				{Type: "tableRow", Content: []adf.Node{{Type: "tableCell", Content: []adf.Node{paragraph(text("only"), adf.Node{Type: "hardBreak"}, text("cell"))}}}},
+			}}},
+			want: "| Name | Value |\n| --- | --- |\n| a\\|b | 1 |\n| only<br>cell |  |",
+		},
+		"Inline nodes": {
+			content: []adf.Node{paragraph(
+				adf.Node{Type: "mention", Attrs: map[string]any{"id": "123", "text": "@Alice"}}, text(" "),
+				adf.Node{Type: "mention", Attrs: map[string]any{"id": "456"}}, text(" "),
+				adf.Node{Type: "emoji", Attrs: map[string]any{"shortName": ":smile:", "text": "😄"}}, text(" "),
+				adf.Node{Type: "emoji", Attrs: map[string]any{"shortName": ":custom:"}}, text(" "),
+				adf.Node{Type: "status", Attrs: map[string]any{"text": "IN PROGRESS"}}, text(" "),
+				adf.Node{Type: "date", Attrs: map[string]any{"timestamp": "1749549600000"}}, text(" "),
+				adf.Node{Type: "inlineCard", Attrs: map[string]any{"url": "https://example.com/card"}},
+			)},
+			want: "@Alice @456 😄 :custom: [IN PROGRESS] 2025-06-10 https://example.com/card",
+		},
+		"Media and cards": {
+			content: []adf.Node{{Type: "mediaSingle"}, {Type: "blockCard", Attrs: map[string]any{"url": "https://example.com"}}},
+			want:    "[attachment]\n\nhttps://example.com",
+		},
+		"Unknown blocks render their content": {
+			content: []adf.Node{{Type: "layoutSection", Content: []adf.Node{paragraph(text("In a layout"))}}},
+			want:    "In a layout",
+		},
+		"Empty document": {want: ""},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := adf.ToMarkdown(adf.Node{Type: "doc", Version: 1, Content: tc.content})
+			if got != tc.want {
+				t.Errorf("ToMarkdown returned:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+}
+
+// text returns a text node with marks.
+func text(s string, marks ...adf.Mark) adf.Node {
+	return adf.Node{Type: "text", Text: s, Marks: marks}
+}
+
+// link returns a link mark to href.
+func link(href string) adf.Mark {
+	return adf.Mark{Type: "link", Attrs: map[string]any{"href": href}}
+}
+
+// paragraph returns a paragraph of inline nodes.
+func paragraph(content ...adf.Node) adf.Node {
+	return adf.Node{Type: "paragraph", Content: content}
+}
+
+// list returns a list of the given type with its items.
+func list(listType string, attrs map[string]any, items ...adf.Node) adf.Node {
+	return adf.Node{Type: listType, Attrs: attrs, Content: items}
+}
+
+// item returns a list item of block nodes.
+func item(content ...adf.Node) adf.Node {
+	return adf.Node{Type: "listItem", Content: content}
+}
+
+// row returns a table row of single paragraph cells of the given type.
+func row(cellType string, cells ...string) adf.Node {
+	r := adf.Node{Type: "tableRow"}
+	for _, c := range cells {
+		r.Content = append(r.Content, adf.Node{Type: cellType, Content: []adf.Node{paragraph(text(c))}})
+	}
+	return r
+}
@@ -0,0 +1,383 @@
+package adf
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	headingRE  = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	ruleRE     = regexp.MustCompile(`^(-{3,}|\*{3,}|_{3,})$`)
+	listItemRE = regexp.MustCompile(`^(\s*)([-*+]|\d+[.)])\s+(.*)$`)
+	tableSepRE = regexp.MustCompile(`^\|?\s*:?-{3,}:?\s*(\|\s*:?-{3,}:?\s*)*\|?$`)
+	linkRE     = regexp.MustCompile(`^\[([^\]]+)\]\(([^)\s]+)\)`)
+	autoLinkRE = regexp.MustCompile(`^https?://[^\s<>()]+`)
+)
+
+// FromMarkdown converts Markdown to an ADF document.
+// It supports the Markdown subset we render ourselves: paragraphs, headings, lists, code blocks, quotes,
+// rules, tables, emphasis, inline code and links. Line breaks inside paragraphs are preserved.
+func FromMarkdown(md string) Node {
+	lines := strings.Split(strings.ReplaceAll(md, "\r\n", "\n"), "\n")
+	return Node{
+		Type:    "doc",
+		Version: 1,
+		Content: parseBlocks(lines),
+	}
+}
+
+// parseBlocks parses lines as a sequence of block nodes.
+func parseBlocks(lines []string) []Node {
+	var nodes []Node
+	for i := 0; i < len(lines); {
+		trimmed := strings.TrimSpace(lines[i])
+
+		switch {
+		case trimmed == "":
+			i++
+
+		case strings.HasPrefix(trimmed, "```"):
+			n := Node{Type: "codeBlock"}
+			if lang := strings.TrimSpace(strings.TrimPrefix(trimmed, "```")); lang != "" {
+				n.Attrs = map[string]any{"language": lang}
+			}
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), "```"); i++ {
+				code = append(code, lines[i])
+			}
+			// Skip closing fence.
+			i++
+			if len(code) > 0 {
+				n.Content = []Node{{Type: "text", Text: strings.Join(code, "\n")}}
+			}
+			nodes = append(nodes, n)
+
+		case headingRE.MatchString(trimmed):
+			m := headingRE.FindStringSubmatch(trimmed)
+			nodes = append(nodes, Node{
+				Type:    "heading",
+				Attrs:   map[string]any{"level": len(m[1])},
+				Content: parseInline(m[2]),
+			})
+			i++
+
+		case ruleRE.MatchString(trimmed):
+			nodes = append(nodes, Node{Type: "rule"})
+			i++
+
+		case strings.HasPrefix(trimmed, ">"):
+			var quoted []string
+			for ; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">"); i++ {
+				q := strings.TrimPrefix(strings.TrimSpace(lines[i]), ">")
+				quoted = append(quoted, strings.TrimPrefix(q, " "))
+			}
+			nodes = append(nodes, Node{Type: "blockquote", Content: parseBlocks(quoted)})
+
+		case strings.HasPrefix(trimmed, "|") && i+1 < len(lines) && tableSepRE.MatchString(strings.TrimSpace(lines[i+1])):
+			var n Node
+			n, i = parseTable(lines, i)
+			nodes = append(nodes, n)
+
+		case listItemRE.MatchString(lines[i]):
+			var n Node
+			n, i = parseList(lines, i)
+			nodes = append(nodes, n)
+
+		default:
+			var content []Node
+			for ; i < len(lines) && !startsBlock(lines[i]); i++ {
+				if len(content) > 0 {
+					content = append(content, Node{Type: "hardBreak"})
+				}
+				content = append(content, parseInline(strings.TrimSpace(lines[i]))...)
+			}
+			nodes = append(nodes, Node{Type: "paragraph", Content: content})
+		}
+	}
+
+	return nodes
+}
+
+// startsBlock returns if the line ends a paragraph, by being empty or starting another block.
+func startsBlock(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return trimmed == "" ||
+		strings.HasPrefix(trimmed, "```") ||
+		strings.HasPrefix(trimmed, ">") ||
+		headingRE.MatchString(trimmed) ||
+		ruleRE.MatchString(trimmed) ||
+		listItemRE.MatchString(line)
+}
+
+// parseList parses a list starting at lines[start] and returns it with the index of the next line to parse.
+// Lines indented deeper than the item marker belong to that item, which allows nesting other blocks.
+func parseList(lines []string, start int) (Node, int) {
+	first := listItemRE.FindStringSubmatch(lines[start])
+	indent := leadingSpaces(first[1])
+	ordered := unicode.IsDigit(rune(first[2][0]))
+
+	list := Node{Type: "bulletList"}
+	if ordered {
+		list.Type = "orderedList"
+		if order, err := strconv.Atoi(strings.TrimRight(first[2], ".)")); err == nil && order != 1 {
+			list.Attrs = map[string]any{"order": order}
+		}
+	}
+
+	i := start
+	for i < len(lines) {
+		m := listItemRE.FindStringSubmatch(lines[i])
+		if m == nil || leadingSpaces(m[1]) != indent || unicode.IsDigit(rune(m[2][0])) != ordered {
+			break
+		}
+
+		contentIndent := indent + len(m[2]) + 1
+		itemLines := []string{m[3]}
+		for i++; i < len(lines); i++ {
+			line := lines[i]
+			if strings.TrimSpace(line) == "" {
+				// A blank line only continues the item if the next non blank line is indented.
+				next := i + 1
+				for next < len(lines) && strings.TrimSpace(lines[next]) == "" {
+					next++
+				}
+				if next >= len(lines) || leadingSpaces(lines[next]) <= indent {
+					break
+				}
+				itemLines = append(itemLines, "")
+				continue
+			}
+			if leadingSpaces(line) <= indent {
+				break
+			}
+			itemLines = append(itemLines, stripIndent(line, contentIndent))
+		}
+
+		// Skip trailing blank lines between items.
+		for i < len(lines) && strings.TrimSpace(lines[i]) == "" && i+1 < len(lines) && listItemRE.MatchString(lines[i+1]) {
+			i++
+		}
+
+		content := parseBlocks(itemLines)
+		if len(content) == 0 || content[0].Type != "paragraph" {
+			content = append([]Node{{Type: "paragraph"}}, content...)
+		}
+		list.Content = append(list.Content, Node{Type: "listItem", Content: content})
+	}
+
+	return list, i
+}
+
+// leadingSpaces returns the number of leading spaces of a line, counting tabs as 4 spaces.
+func leadingSpaces(line string) int {
+	var n int
+	for _, r := range line {
+		switch r {
+		case ' ':
+			n++
+		case '\t':
+			n += 4
+		default:
+			return n
+		}
+	}
+	return n
+}
+
+// stripIndent removes up to columns of leading indentation from line, counting tabs as 4 spaces.
+// A tab crossing the limit is replaced by the spaces left over after it.
+func stripIndent(line string, columns int) string {
+	var n, i int
+	for ; i < len(line) && n < columns; i++ {
+		switch line[i] {
+		case ' ':
+			n++
+		case '\t':
+			n += 4
+		default:
+			return line[i:]
+		}
+	}
+	return strings.Repeat(" ", max(n-columns, 0)) + line[i:]
+}
+
+// parseTable parses a table starting at lines[start] and returns it with the index of the next line to parse.
+// The first row is the header, followed by the separator line.
+func parseTable(lines []string, start int) (Node, int) {
+	t := Node{Type: "table"}
+
+	i := start
+	for ; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), "|"); i++ {
+		if i == start+1 {
+			// Separator line.
+			continue
+		}
+
+		cellType := "tableCell"
+		if i == start {
+			cellType = "tableHeader"
+		}
+
+		row := Node{Type: "tableRow"}
+		for _, cell := range splitTableRow(strings.TrimSpace(lines[i])) {
+			var content []Node
+			for j, part := range strings.Split(cell, "<br>") {
+				if j > 0 {
+					content = append(content, Node{Type: "hardBreak"})
+				}
+				content = append(content, parseInline(strings.TrimSpace(part))...)
+			}
+			row.Content = append(row.Content, Node{
+				Type:    cellType,
+				Content: []Node{{Type: "paragraph", Content: content}},
+			})
+		}
+		t.Content = append(t.Content, row)
+	}
+
+	return t, i
+}
+
+// splitTableRow returns the cells of a Markdown table row, honouring escaped pipes.
+func splitTableRow(row string) []string {
+	row = strings.TrimSuffix(strings.TrimPrefix(row, "|"), "|")
+
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(row); i++ {
+		switch {
+		case row[i] == '\\' && i+1 < len(row) && row[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case row[i] == '|':
+			cells = append(cells, cell.String())
+			cell.Reset()
+		default:
+			cell.WriteByte(row[i])
+		}
+	}
+	return append(cells, cell.String())
+}
+
+// parseInline parses inline Markdown as text nodes with marks.
+func parseInline(s string) []Node {
+	var nodes []Node
+	var text strings.Builder
+	flush := func() {
+		if text.Len() == 0 {
+			return
+		}
+		nodes = append(nodes, Node{Type: "text", Text: text.String()})
+		text.Reset()
+	}
+
+	var prev rune
+	for i := 0; i < len(s); {
+		if elems, size, ok := parseInlineElement(s[i:], prev); ok {
+			flush()
+			nodes = append(nodes, elems...)
+			i += size
+			prev, _ = utf8.DecodeLastRuneInString(s[:i])
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		text.WriteRune(r)
+		prev = r
+		i += size
+	}
+	flush()
+
+	return nodes
+}
+
+// emphasisDelimiters are the Markdown delimiters for marks, longest first.
+var emphasisDelimiters = []struct {
+	delim string
+	mark  string
+}{
+	{"**", "strong"},
+	{"__", "strong"},
+	{"~~", "strike"},
+	{"*", "em"},
+	{"_", "em"},
+}
+
+// parseInlineElement parses the formatted element at the start of s, if any.
+// It returns the parsed nodes and the number of bytes consumed.
+func parseInlineElement(s string, prev rune) (nodes []Node, size int, ok bool) {
+	if strings.HasPrefix(s, "`") {
+		end := strings.Index(s[1:], "`")
+		if end <= 0 {
+			return nil, 0, false
+		}
+		return []Node{{Type: "text", Text: s[1 : end+1], Marks: []Mark{{Type: "code"}}}}, end + 2, true
+	}
+
+	if m := linkRE.FindStringSubmatch(s); m != nil {
+		return addMark(parseInline(m[1]), Mark{Type: "link", Attrs: map[string]any{"href": m[2]}}), len(m[0]), true
+	}
+
+	if !isWordRune(prev) {
+		if url := autoLinkRE.FindString(s); url != "" {
+			url = strings.TrimRight(url, ".,;:!?")
+			return []Node{{Type: "text", Text: url, Marks: []Mark{{Type: "link", Attrs: map[string]any{"href": url}}}}}, len(url), true
+		}
+	}
+
+	for _, e := range emphasisDelimiters {
+		if !strings.HasPrefix(s, e.delim) {
+			continue
+		}
+		// Underscores inside words, like snake_case, are not emphasis.
+		if e.delim[0] == '_' && isWordRune(prev) {
+			return nil, 0, false
+		}
+
+		inner := s[len(e.delim):]
+		end := strings.Index(inner, e.delim)
+		if end <= 0 {
+			continue
+		}
+		inner = inner[:end]
+		if strings.TrimSpace(inner) != inner {
+			continue
+		}
+		return addMark(parseInline(inner), Mark{Type: e.mark}), end + 2*len(e.delim), true
+	}
+
+	return nil, 0, false
+}
+
+// addMark applies the mark to all text nodes.
+// Code can only be combined with links in ADF, so other marks are not applied to inline code.
+func addMark(nodes []Node, mark Mark) []Node {
+	for i, n := range nodes {
+		if n.Type != "text" {
+			continue
+		}
+		if mark.Type != "link" && hasMark(n, "code") {
+			continue
+		}
+		nodes[i].Marks = append(n.Marks, mark)
+	}
+	return nodes
+}
+
+// hasMark returns if the node has a mark of the given type.
+func hasMark(n Node, markType string) bool {
+	for _, m := range n.Marks {
+		if m.Type == markType {
+			return true
+		}
+	}
+	return false
+}
+
+// isWordRune returns if r is part of a word.
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,93 @@
+package adf_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/canonical/jira-summarizer/internal/adf"
+	"github.com/canonical/jira-summarizer/internal/testutils"
+)
+
+func TestFromMarkdown(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		md string
+	}{
+		"Paragraphs with line breaks": {md: "First line\nsecond line\n\nOther paragraph"},
+		"Headings":                    {md: "# Title\n\n### Section\n\n####### Not a heading"},
+		"Marks": {md: "**bold** __bold__ *italic* _italic_ ~~struck~~ `code **not bold**` snake_case_name " +
+			"[link](https://example.com) https://example.com/path."},
+		"Nested marks":               {md: "**bold *and italic* with `code`**"},
+		"Bullet list":                {md: "- one\n* two\n+ three"},
+		"Ordered list with start":    {md: "3. three\n4) four"},
+		"Nested lists":               {md: "- parent\n  1. child\n  2. other child\n     - grandchild\n- sibling"},
+		"Tab indented nested lists":  {md: "- parent\n\t- child\n\t\t- grandchild\n- sibling"},
+		"Tab indented continuation":  {md: "- item\n\tcontinuation\n\n\tsecond paragraph\n- other"},
+		"Loose list":                 {md: "- one\n\n- two"},
+		"Bullet then ordered list":   {md: "- bullet\n1. ordered"},
+		"List item with code block":  {md: "- item\n  ```\n  code\n  ```"},
+		"Code block":                 {md: "```go\nfunc main() {\n\t**not bold**\n}\n```"},
+		"Empty code block":           {md: "```\n```"},
+		"Blockquote":                 {md: "> quoted\n> - item\n>\n> more"},
+		"Rule":                       {md: "Above\n\n---\n\nBelow"},
+		"Table":                      {md: "| Name | Value |\n| --- | :---: |\n| a\\|b | **1** |\n| multi<br>line | |"},
+		"Pipe without table":         {md: "| not a table"},
+		"Windows line endings":       {md: "First\r\nSecond"},
+		"Empty":                      {md: ""},
+		"Paragraph followed by list": {md: "Intro:\n- item"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := json.MarshalIndent(adf.FromMarkdown(tc.md), "", "  ")
+			if err != nil {
+				t.Fatalf("Setup: failed to marshal ADF document: %v", err)
+			}
+			testutils.CheckOrUpdateGolden(t, string(got))
+		})
+	}
+}
+
+func TestMarkdownRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		md string
+
+		// want is the Markdown after the round trip, when it differs from md.
+		want string
+	}{
+		"Paragraphs":           {md: "First line\nsecond line\n\nOther paragraph"},
+		"Headings":             {md: "# Title\n\n###### Section"},
+		"Marks":                {md: "**bold** *italic* ~~struck~~ `code` [link](https://example.com) https://example.com"},
+		"Nested lists":         {md: "- parent\n  1. child\n  2. other child\n     - grandchild\n- sibling"},
+		"Ordered list":         {md: "3. three\n4. four"},
+		"Code block":           {md: "```go\nfunc main() {\n\treturn\n}\n```"},
+		"Blockquote":           {md: "> quoted\n>\n> more"},
+		"Rule":                 {md: "Above\n\n---\n\nBelow"},
+		"Table":                {md: "| Name | Value |\n| --- | --- |\n| a\\|b | **1** |\n| multi<br>line |  |"},
+		"List item paragraphs": {md: "- item\n\n  second paragraph\n- other"},
+
+		"Alternative markers are normalized":   {md: "* one\n+ two\n\n__bold__ _italic_", want: "- one\n- two\n\n**bold** *italic*"},
+		"Tab indented lists are normalized":    {md: "- parent\n\t- child\n\t\t- grandchild\n- sibling", want: "- parent\n  - child\n    - grandchild\n- sibling"},
+		"Tab indented continuation is kept":    {md: "- item\n\tcontinuation\n- other", want: "- item\n  continuation\n- other"},
+		"Ordered list delimiter is normalized": {md: "1) one\n2) two", want: "1. one\n2. two"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			want := tc.want
+			if want == "" {
+				want = tc.md
+			}
+
+			got := adf.ToMarkdown(adf.FromMarkdown(tc.md))
+			if got != want {
+				t.Errorf("Round trip returned:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
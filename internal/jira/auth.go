@@ -0,0 +1,70 @@
+package jira
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Authenticator adds credentials to requests sent to Jira.
+type Authenticator interface {
+	Authenticate(req *http.Request)
+}
+
+// BasicAuth authenticates with a username and a password or API token.
+// This is what Jira Cloud expects, with the account email and an API token.
+type BasicAuth struct {
+	Username string
+	Token    string
+}
+
+// Authenticate adds the Basic Authentication header to the request.
+func (a BasicAuth) Authenticate(req *http.Request) {
+	auth := a.Username + ":" + a.Token
+	encodedAuth := base64.StdEncoding.EncodeToString([]byte(auth))
+	req.Header.Set("Authorization", "Basic "+encodedAuth)
+}
+
+// BearerAuth authenticates with a personal access token, as used by Jira Data Center and Server.
+type BearerAuth struct {
+	Token string
+}
+
+// Authenticate adds the Bearer token header to the request.
+func (a BearerAuth) Authenticate(req *http.Request) {
+	req.Header.Set("Authorization", "Bearer "+a.Token)
+}
+
+// Flavour is the kind of Jira deployment we are talking to.
+type Flavour int
+
+const (
+	// Cloud is the Atlassian hosted Jira.
+	Cloud Flavour = iota
+	// DataCenter is a self-hosted Jira Data Center or Server.
+	DataCenter
+)
+
+// String returns the configuration name of the flavour.
+func (f Flavour) String() string {
+	switch f {
+	case Cloud:
+		return "cloud"
+	case DataCenter:
+		return "datacenter"
+	}
+	return fmt.Sprintf("Flavour(%d)", int(f))
+}
+
+// ParseFlavour returns the flavour matching its configuration name.
+// An empty name defaults to Cloud.
+func ParseFlavour(name string) (Flavour, error) {
+	switch strings.ToLower(name) {
+	case "", "cloud":
+		return Cloud, nil
+	case "datacenter", "server":
+		return DataCenter, nil
+	}
+	return Cloud, fmt.Errorf("unknown jira flavour %q. Valid options are: cloud, datacenter", name)
+}
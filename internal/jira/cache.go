@@ -0,0 +1,172 @@
+package jira
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CacheMode selects how the on-disk cache is used.
+type CacheMode int
+
+const (
+	// CacheDefault serves fresh cached responses, and fetches and stores the others.
+	CacheDefault CacheMode = iota
+	// CacheRefresh always fetches responses from Jira, and stores them.
+	CacheRefresh
+	// CacheOffline only serves cached responses, whatever their age, and never contacts Jira.
+	CacheOffline
+)
+
+// ErrNotCached is returned in offline mode when a request has no cached response.
+var ErrNotCached = errors.New("no cached response in offline mode")
+
+// cacheTransport caches successful GET responses on disk.
+// Entries are keyed by URL and credentials, so that users never see each other’s data.
+type cacheTransport struct {
+	next http.RoundTripper
+	dir  string
+	ttl  time.Duration
+	mode CacheMode
+}
+
+// cacheEntry is a cached response, as stored on disk.
+type cacheEntry struct {
+	URL    string
+	Stored time.Time
+	Header http.Header
+	Body   []byte
+}
+
+// RoundTrip serves the request from the cache when possible, and stores successful responses.
+func (t cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		if t.mode == CacheOffline {
+			return nil, fmt.Errorf("can't %s %s: %w", req.Method, req.URL, ErrNotCached)
+		}
+		return t.next.RoundTrip(req)
+	}
+
+	path := t.entryPath(req)
+
+	if t.mode != CacheRefresh {
+		entry, err := readCacheEntry(path)
+		switch {
+		case err == nil && (t.mode == CacheOffline || time.Since(entry.Stored) < t.ttl):
+			slog.Debug(fmt.Sprintf("GET %s: served from cache stored on %s", req.URL.Path, entry.Stored.Format(time.DateTime)))
+			return entry.response(req), nil
+		case err != nil && !errors.Is(err, os.ErrNotExist):
+			slog.Debug(fmt.Sprintf("ignoring invalid cache entry %s: %v", path, err))
+		}
+	}
+
+	if t.mode == CacheOffline {
+		return nil, fmt.Errorf("GET %s: %w", req.URL, ErrNotCached)
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	// Cookies are session data, which don't belong on disk.
+	header := resp.Header.Clone()
+	header.Del("Set-Cookie")
+	entry := cacheEntry{
+		URL:    req.URL.String(),
+		Stored: time.Now(),
+		Header: header,
+		Body:   body,
+	}
+	if err := writeCacheEntry(path, entry); err != nil {
+		slog.Warn(fmt.Sprintf("failed to cache response for %s: %v", req.URL.Path, err))
+	}
+
+	return resp, nil
+}
+
+// entryPath returns the path of the cache entry for the request, keyed by its URL and credentials.
+func (t cacheTransport) entryPath(req *http.Request) string {
+	h := sha256.New()
+	h.Write([]byte(req.Header.Get("Authorization")))
+	h.Write([]byte{0})
+	h.Write([]byte(req.URL.String()))
+	return filepath.Join(t.dir, hex.EncodeToString(h.Sum(nil))+".json")
+}
+
+// response builds the HTTP response to req from the cache entry.
+func (e cacheEntry) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        e.Header,
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+// readCacheEntry reads a cache entry from disk.
+func readCacheEntry(path string) (cacheEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cacheEntry{}, err
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return cacheEntry{}, err
+	}
+	return entry, nil
+}
+
+// writeCacheEntry atomically writes a cache entry to disk, only readable by the current user.
+func writeCacheEntry(path string, entry cacheEntry) (err error) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(f.Name())
+		}
+	}()
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,161 @@
+package jira_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/canonical/jira-summarizer/internal/jira"
+	"github.com/canonical/jira-summarizer/internal/jira/jiratest"
+)
+
+func TestCache(t *testing.T) {
+	t.Parallel()
+
+	issues := []jiratest.Issue{
+		{Key: "EPIC-1", IssueType: "Epic", Status: "In Progress", Created: day},
+		{Key: "TASK-1", IssueType: "Task", Status: "To Do", Parent: "EPIC-1", Created: day},
+	}
+
+	tests := map[string]struct {
+		key         string
+		mode        jira.CacheMode
+		auth        jira.Authenticator
+		noFirstRun  bool
+		expireFirst bool
+
+		wantFromCache bool
+		wantErr       error
+	}{
+		"Fresh responses are served from the cache": {wantFromCache: true},
+		"Expired responses are fetched again":       {expireFirst: true},
+		"Refresh fetches responses again":           {mode: jira.CacheRefresh},
+		"Offline serves expired responses":          {mode: jira.CacheOffline, expireFirst: true, wantFromCache: true},
+		"Entries are specific to the credentials":   {auth: jira.BearerAuth{Token: jiratest.Token}},
+
+		"Error when offline without cached responses": {mode: jira.CacheOffline, noFirstRun: true, wantFromCache: true, wantErr: jira.ErrNotCached},
+		"Error responses are not cached":              {key: "MISSING-1", wantErr: jira.ErrNotFound},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.key == "" {
+				tc.key = "EPIC-1"
+			}
+			if tc.auth == nil {
+				tc.auth = jira.BasicAuth{Username: jiratest.Username, Token: jiratest.Token}
+			}
+
+			srv := jiratest.NewServer(t, issues)
+			dir := t.TempDir()
+
+			if !tc.noFirstRun {
+				jc := srv.Client(t, jira.WithCache(dir, time.Hour, jira.CacheDefault))
+				// Errors are checked on the second run, which is the same for error responses.
+				_, _ = jc.GetIssue(context.Background(), tc.key)
+				if len(srv.Requests()) == 0 {
+					t.Fatal("Setup: first run should have reached the server")
+				}
+			}
+			if tc.expireFirst {
+				expireCacheEntries(t, dir, 2*time.Hour)
+			}
+
+			jc, err := jira.NewClient(srv.URL, tc.auth, jira.WithCache(dir, time.Hour, tc.mode))
+			if err != nil {
+				t.Fatalf("Setup: failed to create Jira client: %v", err)
+			}
+			before := len(srv.Requests())
+			_, err = jc.GetIssue(context.Background(), tc.key)
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Errorf("GetIssue returned %v, want %v", err, tc.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("GetIssue returned an unexpected error: %v", err)
+			}
+
+			requests := srv.Requests()[before:]
+			if tc.wantFromCache && len(requests) != 0 {
+				t.Errorf("Requests should have been served from the cache, got %q", requests)
+			}
+			if !tc.wantFromCache && len(requests) == 0 {
+				t.Error("Requests should have reached the server, got none")
+			}
+		})
+	}
+}
+
+func TestCacheEntries(t *testing.T) {
+	t.Parallel()
+
+	srv := jiratest.NewServer(t, []jiratest.Issue{{Key: "EPIC-1", IssueType: "Epic", Status: "In Progress", Created: day}})
+	dir := t.TempDir()
+	jc := srv.Client(t, jira.WithCache(dir, time.Hour, jira.CacheDefault))
+
+	if _, err := jc.GetIssue(context.Background(), "EPIC-1"); err != nil {
+		t.Fatalf("Setup: GetIssue returned an unexpected error: %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Setup: failed to read cache directory: %v", err)
+	}
+	if len(entries) == 0 {
+		t.Fatal("No responses were cached")
+	}
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".tmp-") {
+			t.Errorf("Temporary file %s should have been renamed", e.Name())
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			t.Fatalf("Setup: failed to stat %s: %v", e.Name(), err)
+		}
+		if perm := info.Mode().Perm(); perm != 0600 {
+			t.Errorf("Cache entry %s has permissions %o, want 600", e.Name(), perm)
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			t.Fatalf("Setup: failed to read %s: %v", e.Name(), err)
+		}
+		if strings.Contains(string(data), "Set-Cookie") {
+			t.Errorf("Cache entry %s should not store cookies: %s", e.Name(), data)
+		}
+	}
+}
+
+// expireCacheEntries moves the storage time of all cache entries in dir back by age.
+func expireCacheEntries(t *testing.T, dir string, age time.Duration) {
+	t.Helper()
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		t.Fatalf("Setup: failed to list cache entries: %v", err)
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Setup: failed to read cache entry: %v", err)
+		}
+		var entry map[string]any
+		if err := json.Unmarshal(data, &entry); err != nil {
+			t.Fatalf("Setup: failed to decode cache entry: %v", err)
+		}
+		entry["Stored"] = time.Now().Add(-age)
+		if data, err = json.Marshal(entry); err != nil {
+			t.Fatalf("Setup: failed to encode cache entry: %v", err)
+		}
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			t.Fatalf("Setup: failed to write cache entry: %v", err)
+		}
+	}
+}
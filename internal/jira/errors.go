@@ -0,0 +1,161 @@
+package jira
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Sentinel errors that APIError matches with errors.Is, depending on its status code.
+var (
+	// ErrUnauthorized is returned when Jira rejects our credentials.
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrForbidden is returned when the user has no permission for the request.
+	ErrForbidden = errors.New("forbidden")
+	// ErrNotFound is returned when the requested resource does not exist or is not visible to the user.
+	ErrNotFound = errors.New("not found")
+	// ErrRateLimited is returned when Jira still rate limits us after all retries.
+	ErrRateLimited = errors.New("rate limited")
+)
+
+// maxErrorBodySize is the maximum size of an error response we read.
+const maxErrorBodySize = 64 * 1024
+
+// APIError is an error response from the Jira REST API.
+type APIError struct {
+	StatusCode int
+	Status     string
+	Method     string
+	URL        string
+	// Messages are the general error messages returned by Jira.
+	Messages []string
+	// FieldErrors are the error messages returned by Jira, per field.
+	FieldErrors map[string]string
+}
+
+// newAPIError creates an APIError from an unexpected Jira response, reading its body.
+func newAPIError(resp *http.Response) *APIError {
+	e := &APIError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+	}
+	if resp.Request != nil {
+		e.Method = resp.Request.Method
+		e.URL = resp.Request.URL.String()
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	if err != nil {
+		return e
+	}
+
+	var result struct {
+		ErrorMessages []string
+		Errors        map[string]string
+		// Some endpoints and proxies return a single message instead.
+		Message string
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		// Not a Jira error payload, like an HTML page from a proxy: don't flood the user with it.
+		return e
+	}
+
+	e.Messages = result.ErrorMessages
+	if result.Message != "" {
+		e.Messages = append(e.Messages, result.Message)
+	}
+	e.FieldErrors = result.Errors
+
+	return e
+}
+
+// Error returns the status and messages returned by Jira.
+func (e *APIError) Error() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("got network status: %s", e.Status))
+	if e.URL != "" {
+		sb.WriteString(fmt.Sprintf(" on %s %s", e.Method, e.URL))
+	}
+
+	msgs := slices.Clone(e.Messages)
+	for _, field := range slices.Sorted(maps.Keys(e.FieldErrors)) {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", field, e.FieldErrors[field]))
+	}
+	if len(msgs) > 0 {
+		sb.WriteString(": " + strings.Join(msgs, "; "))
+	}
+
+	return sb.String()
+}
+
+// Is matches the sentinel errors corresponding to the status code.
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	}
+	return false
+}
+
+// KeyError is a non fatal error for a single issue key which could not be retrieved,
+// because it is invalid, does not exist or is not visible to the user.
+type KeyError struct {
+	Key string
+	Err error
+}
+
+// Error returns the failing key with the reason.
+func (e *KeyError) Error() string {
+	return fmt.Sprintf("issue %s: %v", e.Key, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *KeyError) Unwrap() error {
+	return e.Err
+}
+
+// issueRequestError is an error of the request searching or getting issues by their keys, as opposed to errors of
+// the requests for their additional data, like comments or children.
+type issueRequestError struct {
+	err error
+}
+
+// Error returns the underlying error message.
+func (e issueRequestError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e issueRequestError) Unwrap() error {
+	return e.err
+}
+
+// isKeyError returns if the error is due to the requested issue keys themselves, rather than
+// to the connection, the credentials or the additional data of the issues.
+func isKeyError(err error) bool {
+	var reqErr issueRequestError
+	if !errors.As(err, &reqErr) {
+		return false
+	}
+	var apiErr *APIError
+	if !errors.As(reqErr.err, &apiErr) {
+		return false
+	}
+
+	switch apiErr.StatusCode {
+	case http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound:
+		return true
+	}
+	return false
+}
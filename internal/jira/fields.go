@@ -0,0 +1,161 @@
+package jira
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/canonical/jira-summarizer/internal/adf"
+	"github.com/ubuntu/decorate"
+)
+
+// Field is a standard or custom issue field of the Jira instance.
+type Field struct {
+	ID     string
+	Name   string
+	Custom bool
+}
+
+// GetFields lists all issue fields of the Jira instance, to find the IDs of custom fields.
+func (jc *Client) GetFields(ctx context.Context) (fields []Field, err error) {
+	defer decorate.OnError(&err, "failed to list fields")
+
+	if err := jiraGet(ctx, jc, jc.apiPath("/field"), &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// epicLinkFieldName is the name of the custom field linking issues to their epic on Data Center.
+const epicLinkFieldName = "Epic Link"
+
+// epicLinkField returns the ID of the field linking issues to their epic, looking it up once per client.
+// It is empty on Cloud, where epics are the parents of their issues, and on instances without epics.
+func (jc *Client) epicLinkField(ctx context.Context) (id string, err error) {
+	if jc.flavour != DataCenter {
+		return "", nil
+	}
+
+	jc.epicLink.mu.Lock()
+	defer jc.epicLink.mu.Unlock()
+	if jc.epicLink.resolved {
+		return jc.epicLink.id, nil
+	}
+
+	fields, err := jc.GetFields(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up the %s field: %w", epicLinkFieldName, err)
+	}
+	for _, f := range fields {
+		if f.Custom && strings.EqualFold(f.Name, epicLinkFieldName) {
+			jc.epicLink.id = f.ID
+			break
+		}
+	}
+	jc.epicLink.resolved = true
+
+	return jc.epicLink.id, nil
+}
+
+// decodeIssue decodes a JSON issue. Its raw fields are only kept to extract custom fields and, on Data Center,
+// the epic link from, to avoid decoding every issue twice.
+func (jc *Client) decodeIssue(data json.RawMessage) (j jsonIssue, err error) {
+	if err := json.Unmarshal(data, &j); err != nil {
+		return jsonIssue{}, err
+	}
+	if len(jc.customFields) == 0 && jc.flavour != DataCenter {
+		return j, nil
+	}
+
+	var raw struct {
+		Fields map[string]json.RawMessage
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return jsonIssue{}, err
+	}
+	j.RawFields = raw.Fields
+
+	return j, nil
+}
+
+// setCustomFields extracts the configured custom fields of the issue as text. Empty fields are omitted.
+func (i *Issue) setCustomFields(rawFields map[string]json.RawMessage, customFields map[string]string) {
+	for name, id := range customFields {
+		raw, ok := rawFields[id]
+		if !ok {
+			continue
+		}
+
+		var v any
+		if err := json.Unmarshal(raw, &v); err != nil {
+			slog.Warn(fmt.Sprintf("failed to parse field %s (%s) for issue %s: %v", name, id, i.Key, err))
+			continue
+		}
+		value := fieldText(v)
+		if value == "" {
+			continue
+		}
+
+		if i.CustomFields == nil {
+			i.CustomFields = make(map[string]string)
+		}
+		i.CustomFields[name] = value
+	}
+}
+
+// dcSprintRE matches the name in the string representation of sprints on Data Center.
+var dcSprintRE = regexp.MustCompile(`^com\.atlassian\.greenhopper\.service\.sprint\.Sprint@.*[\[,]name=([^,\]]*)`)
+
+// fieldText returns a text representation of a decoded field value.
+// Objects, like users, options, sprints or teams, are represented by their name and lists by their elements.
+func fieldText(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		if m := dcSprintRE.FindStringSubmatch(v); m != nil {
+			return m[1]
+		}
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case []any:
+		var values []string
+		for _, e := range v {
+			if t := fieldText(e); t != "" {
+				values = append(values, t)
+			}
+		}
+		return strings.Join(values, ", ")
+	case map[string]any:
+		// Rich text fields on Cloud.
+		if v["type"] == "doc" {
+			raw, err := json.Marshal(v)
+			if err != nil {
+				return ""
+			}
+			text, err := adf.TextOrMarkdown(raw)
+			if err != nil {
+				return ""
+			}
+			return strings.TrimSpace(text)
+		}
+		for _, key := range []string{"displayName", "value", "name", "title"} {
+			if s, ok := v[key].(string); ok {
+				return s
+			}
+		}
+	}
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(raw)
+}
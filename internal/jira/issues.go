@@ -4,31 +4,62 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"iter"
 	"log/slog"
-	"net/http"
-	"net/url"
+	"maps"
+	"regexp"
+	"slices"
 	"strings"
 	"time"
 
+	"github.com/canonical/jira-summarizer/internal/adf"
 	"github.com/ubuntu/decorate"
 	"golang.org/x/sync/errgroup"
 )
 
 // Issue represents a Jira issue (epic or subtask)
 type Issue struct {
-	Key         string
+	Key string
+	// ID is the stable identifier of the issue, which does not change when it is moved.
+	ID string
+	// FormerKeys are the keys of the issue before being moved to other projects.
+	FormerKeys  []string
 	URL         string
 	Summary     string
 	Description string
 	Created     time.Time
 	IssueType   string
-	Status      struct {
+	Assignee    string
+	Reporter    string
+	Priority    string
+	Labels      []string
+	Components  []string
+	FixVersions []string
+	// DueDate is the day the issue is due, or zero if there is none.
+	DueDate time.Time
+	// Resolution is empty while the issue is unresolved.
+	Resolution     string
+	ResolutionDate time.Time
+	// Done is set when the issue status is in the done category.
+	Done bool
+	// CustomFields are the configured custom fields which are set, as text by friendly name.
+	CustomFields map[string]string
+	Status       struct {
 		Name string
 		Who  string
 		When time.Time
 	}
-	Children []Issue
-	Comments []Comment
+	// Transitions are the status changes in the requested window, in ascending order.
+	Transitions []Transition
+	// Changes are the changes of other fields in the requested window, in ascending order.
+	Changes []Change
+	Links   []Link
+	// LinkChanges are the links added or removed in the requested window, in ascending order.
+	LinkChanges []LinkChange
+	Children    []Issue
+	Comments    []Comment
+	// Worklogs are the time logged on the issue for work started in the requested window, in ascending order.
+	Worklogs []Worklog
 }
 
 // Comment represent a comment on a Jira issue.
@@ -38,52 +69,93 @@ type Comment struct {
 	When    time.Time
 }
 
-// Embedder returns if top issues is only used only as embedder:
-// - it’s either a virtual issue (no key)
-// - or it has children
-func (i Issue) Embedder() bool {
-	if i.Key == "" || len(i.Children) > 0 {
-		return true
-	}
+// Transition is a change of status of an issue.
+type Transition struct {
+	From string
+	To   string
+	Who  string
+	When time.Time
+}
 
-	return false
+// Change is a change of any other field than the status of an issue.
+type Change struct {
+	// Field is the name of the field in the changelog, like "assignee", "priority" or "Fix Version".
+	Field string
+	// From and To are the displayed values. They are empty when the field was unset or cleared.
+	From string
+	To   string
+	Who  string
+	When time.Time
 }
 
-// AddComment adds a comment to an issue.
-func (i *Issue) AddComment(jc *Client, commentBody string) (err error) {
-	defer decorate.OnError(&err, "failed to add comment on issue %s", i.Key)
+// Link is a link from an issue to another one.
+type Link struct {
+	// Relation describes the link from the issue point of view, like "blocks", "is blocked by" or "relates to".
+	Relation string
+	Key      string
+	Summary  string
+	Status   string
+	// Done is set when the linked issue status is in the done category.
+	Done bool
+}
 
-	path := fmt.Sprintf("/rest/api/2/issue/%s/comment", i.Key)
+// blockedByRelation is the relation of issues to the issues blocking them.
+const blockedByRelation = "is blocked by"
 
-	d := fmt.Sprintf(`{"body": %s}`, formatJSONString(commentBody))
-	req, err := jc.createRequest(context.Background(), "POST", path, d)
-	if err != nil {
-		return err
-	}
+// LinkChange is a link added to or removed from an issue.
+type LinkChange struct {
+	// Description is the link as described by Jira, like "This issue is blocked by ABC-123".
+	Description string
+	Key         string
+	Removed     bool
+	Who         string
+	When        time.Time
+}
 
-	resp, err := jc.client.Do(req)
-	if err != nil {
-		return err
+// OpenBlockers returns the links to the issues blocking this one which are not done yet.
+func (i Issue) OpenBlockers() []Link {
+	var blockers []Link
+	for _, l := range i.Links {
+		if !strings.EqualFold(l.Relation, blockedByRelation) || l.Done {
+			continue
+		}
+		blockers = append(blockers, l)
 	}
-	defer resp.Body.Close()
+	return blockers
+}
 
-	if resp.StatusCode != http.StatusCreated {
-		return fmt.Errorf("got network status: %s", resp.Status)
-	}
+// DefaultChangeFields are the fields whose changes count as activity and are shown, when not configured.
+var DefaultChangeFields = []string{"assignee", "priority", "duedate", "description", "resolution", "Fix Version"}
 
-	/* Let’s not refresh the issue after adding a comment for now, as it can be expensive
-	and they are not reused during the program execution for now.
-	if *i, err = i.fetchComments(jc); err != nil {
-		return fmt.Errorf("failed to refresh issue after adding comment: %v", err)
-	}*/
+// ChangeFilter selects which field changes count as activity on issues and which are shown.
+// Fields are names in the changelog, matched case insensitively. Status transitions always count and are shown.
+type ChangeFilter struct {
+	Activity []string
+	Shown    []string
+}
 
-	return nil
+// matches returns if the change is on one of the fields.
+func (c Change) matches(fields []string) bool {
+	return slices.ContainsFunc(fields, func(f string) bool { return strings.EqualFold(f, c.Field) })
+}
+
+// Embedder returns if top issues is only used only as embedder:
+// - it’s either a virtual issue (no key)
+// - or it has children
+func (i Issue) Embedder() bool {
+	if i.Key == "" || len(i.Children) > 0 {
+		return true
+	}
+
+	return false
 }
 
 // KeptRecentEvents filters issues to only include those with recent changes.
-// Those can be recent comments or status changes.
+// Those can be recent comments, worklogs, status transitions, link changes or changes of the activity fields of the
+// filter.
+// Only changes of the shown fields of the filter are kept.
 // It will signal if any changed happened on that issue or any of its children.
-func (i *Issue) KeptRecentEvents(sinceTime time.Time) (hasChanged bool) {
+func (i *Issue) KeptRecentEvents(sinceTime time.Time, changes ChangeFilter) (hasChanged bool) {
 	var hasChanges bool
 
 	if i.Created.After(sinceTime) {
@@ -96,6 +168,40 @@ func (i *Issue) KeptRecentEvents(sinceTime time.Time) (hasChanged bool) {
 		i.Status.Name = ""
 	}
 
+	var recentTransitions []Transition
+	for _, transition := range i.Transitions {
+		if transition.When.Before(sinceTime) {
+			continue
+		}
+		hasChanges = true
+		recentTransitions = append(recentTransitions, transition)
+	}
+	i.Transitions = recentTransitions
+
+	var recentChanges []Change
+	for _, change := range i.Changes {
+		if change.When.Before(sinceTime) {
+			continue
+		}
+		if change.matches(changes.Activity) {
+			hasChanges = true
+		}
+		if change.matches(changes.Shown) {
+			recentChanges = append(recentChanges, change)
+		}
+	}
+	i.Changes = recentChanges
+
+	var recentLinkChanges []LinkChange
+	for _, change := range i.LinkChanges {
+		if change.When.Before(sinceTime) {
+			continue
+		}
+		hasChanges = true
+		recentLinkChanges = append(recentLinkChanges, change)
+	}
+	i.LinkChanges = recentLinkChanges
+
 	var recentComments []Comment
 	for _, comment := range i.Comments {
 		if comment.When.Before(sinceTime) {
@@ -106,9 +212,19 @@ func (i *Issue) KeptRecentEvents(sinceTime time.Time) (hasChanged bool) {
 	}
 	i.Comments = recentComments
 
+	var recentWorklogs []Worklog
+	for _, worklog := range i.Worklogs {
+		if worklog.Started.Before(sinceTime) {
+			continue
+		}
+		hasChanges = true
+		recentWorklogs = append(recentWorklogs, worklog)
+	}
+	i.Worklogs = recentWorklogs
+
 	var children []Issue
 	for _, child := range i.Children {
-		if !child.KeptRecentEvents(sinceTime) {
+		if !child.KeptRecentEvents(sinceTime, changes) {
 			continue
 		}
 		hasChanges = true
@@ -142,7 +258,10 @@ func (i Issue) Format(indent bool) string {
 	return prefix + strings.ReplaceAll(sb.String(), "\n", "\n"+prefix)
 }
 
-const timeFormat = "02/01/2006 15:04"
+const (
+	timeFormat = "02/01/2006 15:04"
+	dateFormat = "02/01/2006"
+)
 
 // String returns a string representation of the issue.
 func (i Issue) String() string {
@@ -161,10 +280,81 @@ Link: %s
 Created on: %s
 `, i.Summary, i.URL, i.Created.Format(timeFormat)))
 
+	if len(i.FormerKeys) > 0 {
+		sb.WriteString(fmt.Sprintf("Previously known as: %s\n", strings.Join(i.FormerKeys, ", ")))
+	}
+
+	// Fields are only shown when they changed in the window or when they matter for a due date slip.
+	dueDateSlip := i.dueDateSlip()
+	if i.Assignee != "" && i.changed("assignee") {
+		sb.WriteString(fmt.Sprintf("Assignee: %s\n", i.Assignee))
+	}
+	if i.Reporter != "" && i.changed("reporter") {
+		sb.WriteString(fmt.Sprintf("Reporter: %s\n", i.Reporter))
+	}
+	if i.Priority != "" && (i.changed("priority") || dueDateSlip != "") {
+		sb.WriteString(fmt.Sprintf("Priority: %s\n", i.Priority))
+	}
+	if len(i.Labels) > 0 && i.changed("labels") {
+		sb.WriteString(fmt.Sprintf("Labels: %s\n", strings.Join(i.Labels, ", ")))
+	}
+	if len(i.Components) > 0 && i.changed("Component") {
+		sb.WriteString(fmt.Sprintf("Components: %s\n", strings.Join(i.Components, ", ")))
+	}
+	if len(i.FixVersions) > 0 && i.changed("Fix Version") {
+		sb.WriteString(fmt.Sprintf("Fix versions: %s\n", strings.Join(i.FixVersions, ", ")))
+	}
+	if !i.DueDate.IsZero() && (i.changed("duedate") || dueDateSlip != "") {
+		sb.WriteString(fmt.Sprintf("Due date: %s", i.DueDate.Format(dateFormat)))
+		if dueDateSlip != "" {
+			sb.WriteString(" (" + dueDateSlip + ")")
+		}
+		sb.WriteString("\n")
+	}
+	for _, name := range slices.Sorted(maps.Keys(i.CustomFields)) {
+		sb.WriteString(fmt.Sprintf("%s: %s\n", name, i.CustomFields[name]))
+	}
+	if i.Resolution != "" && i.changed("resolution") {
+		sb.WriteString(fmt.Sprintf("Resolved as %s on %s\n", i.Resolution, i.ResolutionDate.Format(timeFormat)))
+	}
+
 	if i.Status.Name != "" {
 		sb.WriteString(fmt.Sprintf("Status changed to %s on %s by %s\n", i.Status.Name, i.Status.When.Format(timeFormat), i.Status.Who))
 	}
 
+	// A single transition is already the status change above.
+	if len(i.Transitions) > 1 {
+		sb.WriteString("Status transitions:\n")
+		for _, t := range i.Transitions {
+			sb.WriteString(fmt.Sprintf("  - %s → %s on %s by %s\n", t.From, t.To, t.When.Format(timeFormat), t.Who))
+		}
+	}
+
+	if len(i.Changes) > 0 {
+		sb.WriteString("Field changes:\n")
+		for _, c := range i.Changes {
+			sb.WriteString(fmt.Sprintf("  - %s on %s by %s\n", c.describe(), c.When.Format(timeFormat), c.Who))
+		}
+	}
+
+	if len(i.Links) > 0 {
+		sb.WriteString("Links:\n")
+		for _, l := range i.Links {
+			sb.WriteString(fmt.Sprintf("  - %s %s (%s): %s\n", l.Relation, l.Key, l.Status, l.Summary))
+		}
+	}
+
+	if len(i.LinkChanges) > 0 {
+		sb.WriteString("Link changes:\n")
+		for _, c := range i.LinkChanges {
+			action := "Added"
+			if c.Removed {
+				action = "Removed"
+			}
+			sb.WriteString(fmt.Sprintf("  - %s on %s by %s: %s\n", action, c.When.Format(timeFormat), c.Who, c.Description))
+		}
+	}
+
 	sb.WriteString(fmt.Sprintf("Description: %s\n", strings.ReplaceAll(strings.TrimSpace(i.Description), "\n", "\n  ")))
 
 	if len(i.Comments) > 0 {
@@ -174,6 +364,17 @@ Created on: %s
 		}
 	}
 
+	if len(i.Worklogs) > 0 {
+		sb.WriteString("Worklogs:\n")
+		for _, w := range i.Worklogs {
+			sb.WriteString(fmt.Sprintf("  - %s (%s): %s", w.Who, w.Started.Format(timeFormat), FormatTimeSpent(w.TimeSpent)))
+			if comment := strings.TrimSpace(w.Comment); comment != "" {
+				sb.WriteString(": " + strings.ReplaceAll(comment, "\n", "\n      "))
+			}
+			sb.WriteString("\n")
+		}
+	}
+
 	if len(i.Children) > 0 {
 		sb.WriteString(fmt.Sprintf("Number of modified direct children tasks: %d\n", len(i.Children)))
 	}
@@ -181,40 +382,219 @@ Created on: %s
 	return sb.String()
 }
 
+// changed returns if any of the fields changed in the window.
+func (i Issue) changed(fields ...string) bool {
+	return slices.ContainsFunc(i.Changes, func(c Change) bool { return c.matches(fields) })
+}
+
+// dueDateSlip describes how the issue missed its due date, if it did: either it is still open after it,
+// or it was resolved in the window after it.
+func (i Issue) dueDateSlip() string {
+	if i.DueDate.IsZero() {
+		return ""
+	}
+
+	// The due date is a whole day.
+	dueEnd := i.DueDate.AddDate(0, 0, 1)
+	switch {
+	case i.Resolution == "" && time.Now().After(dueEnd):
+		return "open past the due date"
+	case i.Resolution != "" && i.changed("resolution") && !i.ResolutionDate.Before(dueEnd):
+		return "resolved after the due date"
+	}
+	return ""
+}
+
 // jsonIssue is a JSON representation of a Jira issue.
 type jsonIssue struct {
+	ID     string
 	Key    string
 	Fields struct {
-		Summary     string
-		Description string
+		Summary string
+		// Description is a string on v2 API and an ADF document on v3.
+		Description json.RawMessage
 		Created     string
 		IssueType   struct {
 			Name string
 		}
 		Status struct {
+			Name           string
+			StatusCategory struct {
+				Key string
+			}
+		}
+		Assignee *struct {
+			DisplayName string
+		}
+		Reporter *struct {
+			DisplayName string
+		}
+		Priority *struct {
+			Name string
+		}
+		Labels     []string
+		Components []struct {
+			Name string
+		}
+		FixVersions []struct {
+			Name string
+		}
+		// DueDate is a day without time.
+		DueDate    string
+		Resolution *struct {
 			Name string
 		}
+		ResolutionDate string
+		IssueLinks     []struct {
+			Type struct {
+				Inward  string
+				Outward string
+			}
+			// Only one of the linked issues is set, depending on the direction of the link.
+			InwardIssue  *jsonLinkedIssue
+			OutwardIssue *jsonLinkedIssue
+		}
+		Parent *struct {
+			Key string
+		}
+		// Comment is only set when requested in the fields and may be truncated.
+		Comment *struct {
+			MaxResults int
+			Total      int
+			Comments   []jsonComment
+		}
+		// Worklog is only set when requested in the fields and may be truncated.
+		Worklog *struct {
+			MaxResults int
+			Total      int
+			Worklogs   []jsonWorklog
+		}
 	}
+	// Changelog is only set when expanded and may be truncated.
+	Changelog *struct {
+		MaxResults int
+		Total      int
+		Histories  []jsonChangeSet
+	}
+	// RawFields are all fields, including custom ones, as returned by Jira. They are only set with custom fields
+	// and on Data Center.
+	RawFields map[string]json.RawMessage `json:"-"`
 }
 
-const jiraTimeFormat = "2006-01-02T15:04:05.999-0700"
+// parentKey returns the key of the parent of the issue, which is its epic through the epic link field if any.
+func (j jsonIssue) parentKey(epicLink string) string {
+	if j.Fields.Parent != nil {
+		return j.Fields.Parent.Key
+	}
+	if epicLink == "" {
+		return ""
+	}
+
+	var key string
+	if err := json.Unmarshal(j.RawFields[epicLink], &key); err != nil {
+		return ""
+	}
+	return key
+}
+
+// jsonLinkedIssue is the JSON representation of an issue embedded in a link.
+type jsonLinkedIssue struct {
+	Key    string
+	Fields struct {
+		Summary string
+		Status  struct {
+			Name           string
+			StatusCategory struct {
+				Key string
+			}
+		}
+	}
+}
+
+// doneStatusCategory is the key of the category of statuses of resolved issues.
+const doneStatusCategory = "done"
+
+// jiraTimeFormats are the time formats found in Jira responses.
+// Most fields use the first one, with or without milliseconds, but some Jira versions and fields
+// use RFC 3339 offsets, like Z or +02:00.
+var jiraTimeFormats = []string{
+	"2006-01-02T15:04:05.999-0700",
+	time.RFC3339Nano,
+}
+
+// parseJiraTime parses a time returned by Jira in any of the known formats.
+func parseJiraTime(value string) (time.Time, error) {
+	var firstErr error
+	for _, layout := range jiraTimeFormats {
+		t, err := time.Parse(layout, value)
+		if err == nil {
+			return t, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return time.Time{}, firstErr
+}
 
 // newIssueFromJsonIssue creates a new Issue from the json issue representation
-// and initializes it with additional properties of that issue.
+// and initializes it with additional properties of that issue, including all its descendants.
 func newIssueFromJsonIssue(ctx context.Context, j jsonIssue, jc *Client) (Issue, error) {
+	i, err := newIssueNodeFromJsonIssue(ctx, j, jc)
+	if err != nil {
+		return Issue{}, err
+	}
+
+	if err := i.fetchChildren(ctx, jc); err != nil {
+		return Issue{}, fmt.Errorf("failed to fetch additional issue data for %s: %w", j.Key, err)
+	}
+
+	return i, nil
+}
+
+// newIssueNodeFromJsonIssue creates a new Issue from the json issue representation
+// and initializes it with additional properties of that issue, without its children.
+// Comments and changelog embedded in the json issue are used when complete, to save requests.
+func newIssueNodeFromJsonIssue(ctx context.Context, j jsonIssue, jc *Client) (Issue, error) {
 	// converted the created time to time.Time
-	createdTime, err := time.Parse(jiraTimeFormat, j.Fields.Created)
+	createdTime, err := parseJiraTime(j.Fields.Created)
 	if err != nil {
 		return Issue{}, fmt.Errorf("failed to parse created time %s for issue %s: %w", j.Fields.Created, j.Key, err)
 	}
 
+	description, err := adf.TextOrMarkdown(j.Fields.Description)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("failed to parse description for issue %s: %v", j.Key, err))
+		description = string(j.Fields.Description)
+	}
+
+	var dueDate time.Time
+	if j.Fields.DueDate != "" {
+		if dueDate, err = time.Parse(time.DateOnly, j.Fields.DueDate); err != nil {
+			slog.Warn(fmt.Sprintf("failed to parse due date %s for issue %s: %v", j.Fields.DueDate, j.Key, err))
+		}
+	}
+
+	var resolutionDate time.Time
+	if j.Fields.ResolutionDate != "" {
+		if resolutionDate, err = parseJiraTime(j.Fields.ResolutionDate); err != nil {
+			slog.Warn(fmt.Sprintf("failed to parse resolution date %s for issue %s: %v", j.Fields.ResolutionDate, j.Key, err))
+		}
+	}
+
+	// The returned key is always the current one, even when requesting the issue by a former key.
 	i := Issue{
-		Key:         j.Key,
-		URL:         fmt.Sprintf("%s/browse/%s", jc.baseURL, j.Key),
-		Summary:     j.Fields.Summary,
-		Description: j.Fields.Description,
-		Created:     createdTime,
-		IssueType:   j.Fields.IssueType.Name,
+		Key:            j.Key,
+		ID:             j.ID,
+		URL:            fmt.Sprintf("%s/browse/%s", jc.baseURL, j.Key),
+		Summary:        j.Fields.Summary,
+		Description:    description,
+		Created:        createdTime,
+		IssueType:      j.Fields.IssueType.Name,
+		Labels:         j.Fields.Labels,
+		DueDate:        dueDate,
+		ResolutionDate: resolutionDate,
+		Done:           j.Fields.Status.StatusCategory.Key == doneStatusCategory,
 		Status: struct {
 			Name string
 			Who  string
@@ -224,18 +604,68 @@ func newIssueFromJsonIssue(ctx context.Context, j jsonIssue, jc *Client) (Issue,
 		},
 	}
 
-	// Shared context for fetching additional data. First error on an issue cancel all other requests, including children.
-	// If fetchChildren returns an errors, it will also cancel the global context as .Wait() will return the first error.
+	if j.Fields.Assignee != nil {
+		i.Assignee = j.Fields.Assignee.DisplayName
+	}
+	if j.Fields.Reporter != nil {
+		i.Reporter = j.Fields.Reporter.DisplayName
+	}
+	if j.Fields.Priority != nil {
+		i.Priority = j.Fields.Priority.Name
+	}
+	for _, c := range j.Fields.Components {
+		i.Components = append(i.Components, c.Name)
+	}
+	for _, v := range j.Fields.FixVersions {
+		i.FixVersions = append(i.FixVersions, v.Name)
+	}
+	if j.Fields.Resolution != nil {
+		i.Resolution = j.Fields.Resolution.Name
+	}
+	i.setCustomFields(j.RawFields, jc.customFields)
+	for _, l := range j.Fields.IssueLinks {
+		relation, linked := l.Type.Outward, l.OutwardIssue
+		if l.InwardIssue != nil {
+			relation, linked = l.Type.Inward, l.InwardIssue
+		}
+		if linked == nil {
+			continue
+		}
+		i.Links = append(i.Links, Link{
+			Relation: relation,
+			Key:      linked.Key,
+			Summary:  linked.Fields.Summary,
+			Status:   linked.Fields.Status.Name,
+			Done:     linked.Fields.Status.StatusCategory.Key == doneStatusCategory,
+		})
+	}
+
+	// Shared context for fetching additional data. First error on an issue cancel all other requests.
 	g, ctx := errgroup.WithContext(ctx)
-	g.Go(func() error {
-		return i.fetchStatusUpdate(ctx, jc)
-	})
-	g.Go(func() error {
-		return i.fetchComments(ctx, jc)
-	})
-	g.Go(func() error {
-		return i.fetchChildren(ctx, jc)
-	})
+	if c := j.Changelog; c != nil && len(c.Histories) >= c.Total {
+		if err := i.setChangelogEvents(changeSetsNewestFirst(c.Histories), jc.since); err != nil {
+			return Issue{}, err
+		}
+	} else {
+		g.Go(func() error {
+			return i.fetchChangelogEvents(ctx, jc)
+		})
+	}
+	// Comments and worklogs are only fetched when embedded but truncated: absent ones were not requested.
+	if c := j.Fields.Comment; c != nil && len(c.Comments) < c.Total {
+		g.Go(func() error {
+			return i.fetchComments(ctx, jc)
+		})
+	} else if c != nil {
+		i.setComments(c.Comments, jc.since)
+	}
+	if w := j.Fields.Worklog; w != nil && len(w.Worklogs) < w.Total {
+		g.Go(func() error {
+			return i.fetchWorklogs(ctx, jc)
+		})
+	} else if w != nil {
+		i.setWorklogs(w.Worklogs, jc.since)
+	}
 
 	if err := g.Wait(); err != nil {
 		return Issue{}, fmt.Errorf("failed to fetch additional issue data for %s: %w", j.Key, err)
@@ -244,95 +674,347 @@ func newIssueFromJsonIssue(ctx context.Context, j jsonIssue, jc *Client) (Issue,
 	return i, nil
 }
 
-// fetchStatusUpdate marks last recent status change for the issue.
-func (i *Issue) fetchStatusUpdate(ctx context.Context, jc *Client) (err error) {
-	defer decorate.OnError(&err, "failed to check recent status change for issue %s", i.Key)
+// fetchChangelogEvents marks last recent status change for the issue and records its recent transitions and changes.
+func (i *Issue) fetchChangelogEvents(ctx context.Context, jc *Client) (err error) {
+	defer decorate.OnError(&err, "failed to check recent changes for issue %s", i.Key)
 
-	path := fmt.Sprintf("/rest/api/2/issue/%s/changelog", i.Key)
+	return i.setChangelogEvents(jc.changelogNewestFirst(ctx, i.Key), jc.since)
+}
 
-	var result struct {
-		Values []struct {
-			Author struct {
-				DisplayName string
-			}
-			Created string
-			Items   []struct {
-				Field    string
-				ToString string
-			}
+// fetchFormerKeys records the former keys of the issue from its whole changelog, the most recent first, until one
+// of keys is found. This is only needed for issues requested by a key they had before the window.
+func (i *Issue) fetchFormerKeys(ctx context.Context, jc *Client, keys []string) (err error) {
+	defer decorate.OnError(&err, "failed to find former keys of issue %s", i.Key)
+
+	var formerKeys []string
+	for changeSet, err := range jc.changelogNewestFirst(ctx, i.Key) {
+		if err != nil {
+			return err
+		}
+		formerKeys = appendFormerKeys(formerKeys, changeSet)
+		if slices.ContainsFunc(formerKeys, func(k string) bool { return slices.Contains(keys, k) }) {
+			break
 		}
 	}
 
-	if err := jiraGet(ctx, jc, path, &result); err != nil {
-		return err
-	}
+	slices.Reverse(formerKeys)
+	i.FormerKeys = formerKeys
+
+	return nil
+}
+
+// setChangelogEvents marks last status change for the issue from change sets sorted the most recent first,
+// and records all status transitions, changes of other fields and former keys more recent than since.
+// It stops as soon as the changes are older than since.
+func (i *Issue) setChangelogEvents(changeSets iter.Seq2[jsonChangeSet, error], since time.Time) error {
+	i.Transitions = nil
+	i.Changes = nil
+	i.LinkChanges = nil
+	i.FormerKeys = nil
+	// Events are collected the most recent first.
+	defer func() {
+		slices.Reverse(i.Transitions)
+		slices.Reverse(i.Changes)
+		slices.Reverse(i.LinkChanges)
+		slices.Reverse(i.FormerKeys)
+	}()
 
-outer:
-	for _, changeSet := range result.Values {
-		modTime, err := time.Parse(jiraTimeFormat, changeSet.Created)
+	for changeSet, err := range changeSets {
+		if err != nil {
+			return err
+		}
+
+		modTime, err := parseJiraTime(changeSet.Created)
 		if err != nil {
 			slog.Warn(fmt.Sprintf("failed to parse change time %s for issue %s: %v", changeSet.Created, i.Key, err))
 			continue
 		}
 
-		for _, item := range changeSet.Items {
-			if item.Field != "status" {
+		// Older changes can’t be in the requested window.
+		if modTime.Before(since) {
+			return nil
+		}
+
+		i.FormerKeys = appendFormerKeys(i.FormerKeys, changeSet)
+
+		// Items are walked backwards too, so that they are in order once all events are reversed.
+		for _, item := range slices.Backward(changeSet.Items) {
+			if item.Field == linkField {
+				i.LinkChanges = append(i.LinkChanges, newLinkChange(item.FromString, item.ToString, changeSet.Author.DisplayName, modTime))
 				continue
 			}
-			if i.Status.Name != item.ToString {
+			if item.Field != "status" {
+				i.Changes = append(i.Changes, Change{
+					Field: item.Field,
+					From:  item.FromString,
+					To:    item.ToString,
+					Who:   changeSet.Author.DisplayName,
+					When:  modTime,
+				})
 				continue
 			}
 
-			if modTime.After(i.Status.When) {
-				i.Status.Who = changeSet.Author.DisplayName
-				i.Status.When = modTime
-			}
+			i.Transitions = append(i.Transitions, Transition{
+				From: item.FromString,
+				To:   item.ToString,
+				Who:  changeSet.Author.DisplayName,
+				When: modTime,
+			})
 
-			break outer
+			if i.Status.Name != item.ToString || !i.Status.When.IsZero() {
+				continue
+			}
+			i.Status.Who = changeSet.Author.DisplayName
+			i.Status.When = modTime
 		}
 	}
 
 	return nil
 }
 
-// fetchComments attaches all comments to the issue in ascending order.
-func (i *Issue) fetchComments(ctx context.Context, jc *Client) (err error) {
-	defer decorate.OnError(&err, "failed to get issue comments for %s", i.Key)
+// appendFormerKeys appends the keys the issue was moved from in the change set, which are not already in keys.
+func appendFormerKeys(keys []string, changeSet jsonChangeSet) []string {
+	for _, item := range slices.Backward(changeSet.Items) {
+		if item.Field != keyField || item.FromString == "" || slices.Contains(keys, item.FromString) {
+			continue
+		}
+		keys = append(keys, item.FromString)
+	}
+	return keys
+}
+
+// linkField is the field of link changes in the changelog.
+const linkField = "Link"
+
+// keyField is the field of key changes in the changelog, when an issue is moved to another project.
+const keyField = "Key"
+
+// linkedKeyRE matches the key of the linked issue at the end of link descriptions.
+var linkedKeyRE = regexp.MustCompile(`[A-Z][A-Z0-9_]*-[0-9]+$`)
+
+// newLinkChange returns the link change from the changelog values. Added links only have a new value, removed
+// ones only an old value.
+func newLinkChange(from, to, who string, when time.Time) LinkChange {
+	c := LinkChange{
+		Description: to,
+		Who:         who,
+		When:        when,
+	}
+	if to == "" {
+		c.Description = from
+		c.Removed = true
+	}
+	c.Key = linkedKeyRE.FindString(c.Description)
+	return c
+}
 
-	// get all Jira comments for the issue in ascending creation order.
-	path := fmt.Sprintf("/rest/api/2/issue/%s/comment?orderBy=created", i.Key)
+// maxChangeValueLength is the length above which changed values, like descriptions, are not shown.
+const maxChangeValueLength = 80
 
-	var result struct {
-		Comments []struct {
-			Author struct {
-				DisplayName string
+// describe returns a description of the change, without the values when they are too long to be shown.
+func (c Change) describe() string {
+	switch {
+	case len(c.From) > maxChangeValueLength || len(c.To) > maxChangeValueLength ||
+		strings.Contains(c.From, "\n") || strings.Contains(c.To, "\n"):
+		return fmt.Sprintf("%s updated", c.Field)
+	case c.From == "":
+		return fmt.Sprintf("%s set to %s", c.Field, c.To)
+	case c.To == "":
+		return fmt.Sprintf("%s cleared (was %s)", c.Field, c.From)
+	}
+	return fmt.Sprintf("%s changed from %s to %s", c.Field, c.From, c.To)
+}
+
+// jsonChangeSet is a JSON representation of a group of changes made at once on an issue.
+type jsonChangeSet struct {
+	Author struct {
+		DisplayName string
+	}
+	Created string
+	Items   []struct {
+		Field      string
+		FromString string
+		ToString   string
+	}
+}
+
+// changelogPage is one page of the issue changelog, sorted in ascending order.
+type changelogPage struct {
+	StartAt    int
+	MaxResults int
+	Total      int
+	IsLast     bool
+	Values     []jsonChangeSet
+}
+
+// changeSetsNewestFirst returns the change sets, sorted in ascending order, the most recent first.
+func changeSetsNewestFirst(values []jsonChangeSet) iter.Seq2[jsonChangeSet, error] {
+	return func(yield func(jsonChangeSet, error) bool) {
+		for _, v := range slices.Backward(values) {
+			if more := yield(v, nil); !more {
+				return
 			}
-			Created string
-			Body    string
 		}
 	}
-	if err := jiraGet(ctx, jc, path, &result); err != nil {
-		return err
+}
+
+// changelogNewestFirst returns all change sets of an issue, the most recent first.
+// The changelog can only be paginated in ascending order: the first page gives us the total so
+// that we can then walk the remaining pages backwards.
+// Data Center has no changelog endpoint and returns the whole history when expanding the issue.
+func (jc *Client) changelogNewestFirst(ctx context.Context, key string) iter.Seq2[jsonChangeSet, error] {
+	return func(yield func(jsonChangeSet, error) bool) {
+		getPage := func(startAt, maxResults int) (changelogPage, error) {
+			path := jc.apiPath(fmt.Sprintf("/issue/%s/changelog?startAt=%d&maxResults=%d", key, startAt, maxResults))
+			var page changelogPage
+			err := jiraGet(ctx, jc, path, &page)
+			return page, err
+		}
+		yieldReversed := func(values []jsonChangeSet) bool {
+			for v := range changeSetsNewestFirst(values) {
+				if more := yield(v, nil); !more {
+					return false
+				}
+			}
+			return true
+		}
+
+		if jc.flavour == DataCenter {
+			path := jc.apiPath(fmt.Sprintf("/issue/%s?fields=status&expand=changelog", key))
+			var result struct {
+				Changelog struct {
+					Histories []jsonChangeSet
+				}
+			}
+			if err := jiraGet(ctx, jc, path, &result); err != nil {
+				yield(jsonChangeSet{}, err)
+				return
+			}
+			yieldReversed(result.Changelog.Histories)
+			return
+		}
+
+		first, err := getPage(0, pageSize)
+		if err != nil {
+			yield(jsonChangeSet{}, err)
+			return
+		}
+
+		pageLen := len(first.Values)
+		if pageLen > 0 && !first.IsLast {
+			for end := first.Total; end > pageLen; {
+				start := max(end-pageLen, pageLen)
+				page, err := getPage(start, end-start)
+				if err != nil {
+					yield(jsonChangeSet{}, err)
+					return
+				}
+				if len(page.Values) == 0 {
+					break
+				}
+				if !yieldReversed(page.Values[:min(len(page.Values), end-start)]) {
+					return
+				}
+				end = start
+			}
+		}
+
+		yieldReversed(first.Values)
 	}
+}
 
-	for _, comment := range result.Comments {
-		createdTime, err := time.Parse(jiraTimeFormat, comment.Created)
+// jsonComment is a JSON representation of a comment on an issue.
+type jsonComment struct {
+	Author struct {
+		DisplayName string
+	}
+	Created string
+	// Body is a string on v2 API and an ADF document on v3.
+	Body json.RawMessage
+}
+
+// newCommentFromJsonComment creates a new Comment from its json representation.
+func newCommentFromJsonComment(j jsonComment) (Comment, error) {
+	createdTime, err := parseJiraTime(j.Created)
+	if err != nil {
+		return Comment{}, fmt.Errorf("failed to parse comment time %s: %v", j.Created, err)
+	}
+
+	content, err := adf.TextOrMarkdown(j.Body)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("failed to parse comment content: %v", err))
+		content = string(j.Body)
+	}
+
+	return Comment{
+		Content: content,
+		Who:     j.Author.DisplayName,
+		When:    createdTime,
+	}, nil
+}
+
+// setComments attaches the comments, sorted in ascending order, more recent than since to the issue.
+func (i *Issue) setComments(comments []jsonComment, since time.Time) {
+	i.Comments = nil
+	for _, jComment := range comments {
+		comment, err := newCommentFromJsonComment(jComment)
 		if err != nil {
-			slog.Warn(fmt.Sprintf("failed to parse comment time %s for issue %s: %v", comment.Created, i.Key, err))
+			slog.Warn(fmt.Sprintf("issue %s: %v", i.Key, err))
+			continue
+		}
+		if comment.When.Before(since) {
 			continue
 		}
+		i.Comments = append(i.Comments, comment)
+	}
+}
 
-		i.Comments = append(i.Comments, struct {
-			Content string
-			Who     string
-			When    time.Time
-		}{
-			comment.Body,
-			comment.Author.DisplayName,
-			createdTime,
-		})
+// fetchComments attaches all comments to the issue in ascending order.
+// Comments are requested the most recent first, so that we can stop as soon as they are older than
+// the client window.
+func (i *Issue) fetchComments(ctx context.Context, jc *Client) (err error) {
+	defer decorate.OnError(&err, "failed to get issue comments for %s", i.Key)
+
+	var comments []Comment
+pages:
+	for startAt := 0; ; {
+		// get Jira comments for the issue in descending creation order.
+		path := jc.apiPath(fmt.Sprintf("/issue/%s/comment?orderBy=-created&startAt=%d&maxResults=%d", i.Key, startAt, pageSize))
+
+		var result struct {
+			StartAt  int
+			Total    int
+			Comments []jsonComment
+		}
+		if err := jiraGet(ctx, jc, path, &result); err != nil {
+			return err
+		}
+
+		for _, jComment := range result.Comments {
+			comment, err := newCommentFromJsonComment(jComment)
+			if err != nil {
+				slog.Warn(fmt.Sprintf("issue %s: %v", i.Key, err))
+				continue
+			}
+
+			// Next comments are older and out of the requested window.
+			if comment.When.Before(jc.since) {
+				break pages
+			}
+
+			comments = append(comments, comment)
+		}
+
+		startAt = result.StartAt + len(result.Comments)
+		if len(result.Comments) == 0 || startAt >= result.Total {
+			break
+		}
 	}
 
+	// Restore ascending order.
+	slices.Reverse(comments)
+	i.Comments = comments
+
 	return nil
 }
 
@@ -340,35 +1022,192 @@ func (i *Issue) fetchComments(ctx context.Context, jc *Client) (err error) {
 func (i *Issue) fetchChildren(ctx context.Context, jc *Client) (err error) {
 	defer decorate.OnError(&err, "failed to gather children of %s", i.Key)
 
-	jql := fmt.Sprintf("parent = %s", i.Key)
-	encodedJQL := url.QueryEscape(jql)
-	path := fmt.Sprintf("/rest/api/2/search?jql=%s", encodedJQL)
+	return jc.fetchDescendants(ctx, []*Issue{i})
+}
+
+// maxParentsPerQuery limits the number of parent keys in a single children JQL query.
+const maxParentsPerQuery = 50
+
+// jqlTimeMargin widens the window pushed into JQL: Jira interprets dates in the user profile timezone,
+// which may differ from ours. Exact filtering happens later in KeptRecentEvents.
+const jqlTimeMargin = 24 * time.Hour
+
+// childrenQuery is a JQL query for children of a set of issues.
+type childrenQuery struct {
+	jql string
+	// stale children were not updated in the window, and are only fetched to look for recent descendants.
+	stale bool
+}
+
+// staleFields are the only fields requested for stale children, to find their own children.
+const staleFields = "key,issuetype,parent"
+
+// epicIssueType is the issue type of epics.
+const epicIssueType = "Epic"
 
-	var children struct {
-		Issues []jsonIssue
+// childrenQueries returns the queries to find children of the given issues.
+// When the client has a window, only children updated in it are returned, in addition to stale ones which
+// can have their own children: unlike sub-tasks, they can have descendants updated without them being updated.
+// With an epic link field, issues of epics are found through it rather than as children, like on Data Center.
+func (jc *Client) childrenQueries(issues []*Issue, epicLink string) []childrenQuery {
+	var queries []childrenQuery
+	for chunk := range slices.Chunk(issues, maxParentsPerQuery) {
+		var keys, epicKeys []string
+		for _, p := range chunk {
+			keys = append(keys, p.Key)
+			if epicLink != "" && p.IssueType == epicIssueType {
+				epicKeys = append(epicKeys, p.Key)
+			}
+		}
+
+		parents := fmt.Sprintf("parent in (%s)", strings.Join(keys, ", "))
+		if len(epicKeys) > 0 {
+			parents = fmt.Sprintf("(%s OR %q in (%s))", parents, epicLinkFieldName, strings.Join(epicKeys, ", "))
+		}
+		if jc.since.IsZero() {
+			queries = append(queries, childrenQuery{jql: parents})
+			continue
+		}
+
+		since := jc.since.Add(-jqlTimeMargin).Local().Format("2006/01/02 15:04")
+		queries = append(queries,
+			childrenQuery{jql: fmt.Sprintf("%s AND updated >= %q", parents, since)},
+			childrenQuery{jql: fmt.Sprintf("%s AND updated < %q AND issuetype not in subTaskIssueTypes()", parents, since), stale: true},
+		)
+	}
+	return queries
+}
+
+// fetchDescendants retrieves the children of all parents, recursively.
+// A whole hierarchy level is queried at once, instead of one query per issue.
+// Stale children are only searched by key, and only fetched in full and kept if they have recent descendants.
+func (jc *Client) fetchDescendants(ctx context.Context, parents []*Issue) error {
+	if len(parents) == 0 {
+		return nil
 	}
-	if err := jiraGet(ctx, jc, path, &children); err != nil {
+
+	epicLink, err := jc.epicLinkField(ctx)
+	if err != nil {
 		return err
 	}
 
-	i.Children = make([]Issue, 0, len(children.Issues))
-	for _, childJson := range children.Issues {
-		child, err := newIssueFromJsonIssue(ctx, childJson, jc)
-		if err != nil {
+	byKey := make(map[string]*Issue, len(parents))
+	for _, p := range parents {
+		p.Children = nil
+		byKey[p.Key] = p
+	}
+
+	type child struct {
+		jsonIssue
+		stale bool
+	}
+	var jChildren []child
+	for _, q := range jc.childrenQueries(slices.SortedFunc(maps.Values(byKey), func(a, b *Issue) int {
+		return strings.Compare(a.Key, b.Key)
+	}), epicLink) {
+		fields, expand := jc.fields(), searchExpand
+		if q.stale {
+			fields, expand = staleFields, ""
+		}
+		if epicLink != "" {
+			fields += "," + epicLink
+		}
+		for j, err := range jc.searchIssues(ctx, q.jql, fields, expand) {
+			if err != nil {
+				return err
+			}
+			jChildren = append(jChildren, child{j, q.stale})
+		}
+	}
+
+	children := make([]Issue, len(jChildren))
+	g, gCtx := errgroup.WithContext(ctx)
+	for idx, j := range jChildren {
+		if j.stale {
+			children[idx] = Issue{Key: j.Key, ID: j.ID, IssueType: j.Fields.IssueType.Name}
+			continue
+		}
+		g.Go(func() (err error) {
+			children[idx], err = newIssueNodeFromJsonIssue(gCtx, j.jsonIssue, jc)
 			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+
+	// Next hierarchy level.
+	next := make([]*Issue, 0, len(children))
+	for idx := range children {
+		next = append(next, &children[idx])
+	}
+	if err := jc.fetchDescendants(ctx, next); err != nil {
+		return err
+	}
+
+	var staleParents []*Issue
+	for idx, j := range jChildren {
+		if j.stale && len(children[idx].Children) > 0 {
+			staleParents = append(staleParents, &children[idx])
 		}
-		i.Children = append(i.Children, child)
+	}
+	if err := jc.fetchStaleParents(ctx, staleParents); err != nil {
+		return err
+	}
+
+	for idx, child := range children {
+		j := jChildren[idx]
+		if j.stale && len(child.Children) == 0 {
+			continue
+		}
+		parent, ok := byKey[j.parentKey(epicLink)]
+		if !ok {
+			continue
+		}
+		parent.Children = append(parent.Children, child)
 	}
 
 	return nil
 }
 
-// formatJSONString properly escapes a string for use in JSON
-func formatJSONString(s string) string {
-	bytes, err := json.Marshal(s)
-	if err != nil {
-		// Fallback if marshaling fails
-		return fmt.Sprintf("\"%s\"", strings.ReplaceAll(s, "\"", "\\\""))
+// fetchStaleParents fills stale issues, only known by key, with all their data, keeping their children.
+func (jc *Client) fetchStaleParents(ctx context.Context, issues []*Issue) error {
+	if len(issues) == 0 {
+		return nil
+	}
+
+	byKey := make(map[string]*Issue, len(issues))
+	for _, i := range issues {
+		byKey[i.Key] = i
+	}
+
+	var jIssues []jsonIssue
+	for keys := range slices.Chunk(slices.Sorted(maps.Keys(byKey)), maxParentsPerQuery) {
+		jql := fmt.Sprintf("key in (%s)", strings.Join(keys, ", "))
+		for j, err := range jc.searchIssues(ctx, jql, jc.fields(), searchExpand) {
+			if err != nil {
+				return err
+			}
+			jIssues = append(jIssues, j)
+		}
+	}
+
+	g, gCtx := errgroup.WithContext(ctx)
+	for _, j := range jIssues {
+		stale, ok := byKey[j.Key]
+		if !ok {
+			continue
+		}
+		g.Go(func() error {
+			i, err := newIssueNodeFromJsonIssue(gCtx, j, jc)
+			if err != nil {
+				return err
+			}
+			i.Children = stale.Children
+			*stale = i
+			return nil
+		})
 	}
-	return string(bytes)
+
+	return g.Wait()
 }
@@ -0,0 +1,177 @@
+package jira_test
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/canonical/jira-summarizer/internal/jira"
+	"github.com/canonical/jira-summarizer/internal/jira/jiratest"
+	"github.com/canonical/jira-summarizer/internal/testutils"
+)
+
+func TestString(t *testing.T) {
+	t.Parallel()
+
+	for name, issue := range testutils.IssueCases() {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			testutils.CheckOrUpdateGolden(t, issue.String())
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	t.Parallel()
+
+	for name, issue := range testutils.IssueCases() {
+		for _, indent := range []bool{false, true} {
+			testName := name
+			if indent {
+				testName += " indented"
+			}
+			t.Run(testName, func(t *testing.T) {
+				t.Parallel()
+
+				testutils.CheckOrUpdateGolden(t, issue.Format(indent))
+			})
+		}
+	}
+}
+
+func TestKeptRecentEvents(t *testing.T) {
+	t.Parallel()
+
+	since := day.AddDate(0, 0, -7)
+	priority := jira.Change{Field: "priority", From: "Medium", To: "High", Who: "Bob", When: day}
+	rank := jira.Change{Field: "Rank", From: "", To: "Ranked higher", Who: "Bob", When: day}
+	oldPriority := jira.Change{Field: "priority", From: "Low", To: "Medium", Who: "Bob", When: since.AddDate(0, 0, -1)}
+
+	tests := map[string]struct {
+		changes     []jira.Change
+		linkChanges []jira.LinkChange
+		worklogs    []jira.Worklog
+		filter      jira.ChangeFilter
+
+		wantKept    bool
+		wantChanges []jira.Change
+	}{
+		"Changes of activity fields keep the issue": {
+			changes:     []jira.Change{priority},
+			filter:      jira.ChangeFilter{Activity: []string{"Priority"}, Shown: []string{"priority"}},
+			wantKept:    true,
+			wantChanges: []jira.Change{priority},
+		},
+		"Changes of other fields don't keep the issue": {
+			changes:     []jira.Change{rank},
+			filter:      jira.ChangeFilter{Activity: []string{"priority"}, Shown: []string{"Rank"}},
+			wantChanges: []jira.Change{rank},
+		},
+		"Only changes of shown fields are kept": {
+			changes:  []jira.Change{priority, rank},
+			filter:   jira.ChangeFilter{Activity: []string{"Rank"}, Shown: []string{"assignee"}},
+			wantKept: true,
+		},
+		"Link changes keep the issue": {
+			linkChanges: []jira.LinkChange{{Description: "This issue is blocked by OPS-1", Key: "OPS-1", Who: "Bob", When: day}},
+			wantKept:    true,
+		},
+		"Worklogs keep the issue": {
+			worklogs: []jira.Worklog{{Who: "Bob", Started: day, TimeSpent: time.Hour}},
+			wantKept: true,
+		},
+		"Changes before since are dropped": {
+			changes: []jira.Change{oldPriority},
+			filter:  jira.ChangeFilter{Activity: []string{"priority"}, Shown: []string{"priority"}},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			// The issue and its comment are older than the window, so that only changes can keep it.
+			i := jira.Issue{Key: "TASK-1", IssueType: "Task", Created: since.AddDate(0, -1, 0), Changes: tc.changes, LinkChanges: tc.linkChanges,
+				Worklogs: tc.worklogs}
+
+			if got := i.KeptRecentEvents(since, tc.filter); got != tc.wantKept {
+				t.Errorf("KeptRecentEvents returned %t, want %t", got, tc.wantKept)
+			}
+			if !slices.Equal(i.Changes, tc.wantChanges) {
+				t.Errorf("Changes = %+v, want %+v", i.Changes, tc.wantChanges)
+			}
+			if len(i.LinkChanges) != len(tc.linkChanges) {
+				t.Errorf("LinkChanges = %+v, want %+v", i.LinkChanges, tc.linkChanges)
+			}
+			if len(i.Worklogs) != len(tc.worklogs) {
+				t.Errorf("Worklogs = %+v, want %+v", i.Worklogs, tc.worklogs)
+			}
+		})
+	}
+}
+
+func TestRecordedIssues(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		flavour      jira.Flavour
+		key          string
+		customFields map[string]string
+	}{
+		"Cloud issue with custom fields and descendants": {flavour: jira.Cloud, key: "PROJ-1", customFields: map[string]string{
+			"story_points": "customfield_10016",
+			"sprint":       "customfield_10020",
+			"reviewer":     "customfield_10050",
+			"epic_link":    "customfield_10014",
+			"unknown":      "customfield_99999",
+		}},
+		"Data Center issue with custom fields": {flavour: jira.DataCenter, key: "DC-1", customFields: map[string]string{
+			"sprint": "customfield_10100",
+			"risk":   "customfield_10101",
+		}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			jc := jiratest.CassetteClient(t, filepath.Join("testdata", "cassettes", t.Name()+".json"), jira.WithFlavour(tc.flavour), jira.WithCustomFields(tc.customFields))
+
+			issue, err := jc.GetIssue(context.Background(), tc.key)
+			if err != nil {
+				t.Fatalf("GetIssue returned an unexpected error: %v", err)
+			}
+
+			got, err := json.MarshalIndent(issue, "", "  ")
+			if err != nil {
+				t.Fatalf("Failed to serialise issue: %v", err)
+			}
+			testutils.CheckOrUpdateGolden(t, string(got))
+		})
+	}
+}
+
+func TestReplayIgnoresRequestedFields(t *testing.T) {
+	t.Parallel()
+
+	r, err := jiratest.LoadReplayer(filepath.Join("testdata", "cassettes", "TestRecordedIssues", "Cloud_issue_with_custom_fields_and_descendants.json"))
+	if err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	// The cassette was recorded without this custom field.
+	jc, err := jira.NewClient(jiratest.CassetteURL, jira.BasicAuth{Username: jiratest.Username, Token: jiratest.Token},
+		jira.WithTransport(r), jira.WithCustomFields(map[string]string{"team": "customfield_10001"}))
+	if err != nil {
+		t.Fatalf("Setup: failed to create Jira client: %v", err)
+	}
+
+	issue, err := jc.GetIssue(context.Background(), "PROJ-1")
+	if err != nil {
+		t.Fatalf("GetIssue returned an unexpected error: %v", err)
+	}
+	if len(issue.Children) == 0 {
+		t.Errorf("Children of PROJ-1 should be replayed too, got none")
+	}
+}
@@ -2,39 +2,146 @@ package jira
 
 import (
 	"context"
-	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"iter"
+	"log/slog"
+	"maps"
 	"net/http"
 	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/canonical/jira-summarizer/internal/adf"
 	"github.com/ubuntu/decorate"
 	"golang.org/x/sync/errgroup"
 )
 
 // Client handles API communication
 type Client struct {
-	username string
-	token    string
-	baseURL  *url.URL
-	client   *http.Client
+	auth    Authenticator
+	flavour Flavour
+	baseURL *url.URL
+	client  *http.Client
+
+	since time.Time
+	// customFields maps friendly names to the IDs of custom fields to extract from issues.
+	customFields map[string]string
+
+	// epicLink is the ID of the field linking issues to their epic on Data Center, looked up on first use.
+	epicLink struct {
+		mu       sync.Mutex
+		id       string
+		resolved bool
+	}
+}
+
+// defaultMaxConcurrency is the default number of simultaneous requests sent to Jira.
+const defaultMaxConcurrency = 10
+
+type options struct {
+	flavour        Flavour
+	since          time.Time
+	maxConcurrency int
+	cache          *cacheTransport
+	transport      http.RoundTripper
+	customFields   map[string]string
+}
+
+// Option configures the Jira client.
+type Option func(*options)
+
+// WithFlavour selects the kind of Jira deployment to adapt the requests to. Default is Cloud.
+func WithFlavour(flavour Flavour) Option {
+	return func(o *options) {
+		o.flavour = flavour
+	}
+}
+
+// WithSince allows the client to stop fetching comments and history older than since.
+func WithSince(since time.Time) Option {
+	return func(o *options) {
+		o.since = since
+	}
+}
+
+// WithMaxConcurrency limits the number of simultaneous requests sent to Jira.
+// Values less than 1 use the default.
+func WithMaxConcurrency(n int) Option {
+	return func(o *options) {
+		if n < 1 {
+			return
+		}
+		o.maxConcurrency = n
+	}
+}
+
+// WithCache stores successful responses under dir and serves them while younger than ttl,
+// depending on the cache mode.
+func WithCache(dir string, ttl time.Duration, mode CacheMode) Option {
+	return func(o *options) {
+		o.cache = &cacheTransport{
+			dir:  dir,
+			ttl:  ttl,
+			mode: mode,
+		}
+	}
+}
+
+// WithTransport sends requests through rt instead of the default HTTP transport, for instance to record or
+// replay them. Retries and caching still apply on top of it.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(o *options) {
+		o.transport = rt
+	}
 }
 
-// NewClient creates a new Jira client
-func NewClient(baseURL, user, token string) (*Client, error) {
+// WithCustomFields extracts the given fields on issues, keyed by friendly names, like "story_points", to field
+// IDs, like "customfield_10016". IDs differ on each Jira instance and are listed by GetFields.
+func WithCustomFields(fields map[string]string) Option {
+	return func(o *options) {
+		o.customFields = fields
+	}
+}
+
+// NewClient creates a new Jira client authenticating with auth.
+func NewClient(baseURL string, auth Authenticator, args ...Option) (*Client, error) {
+	opts := options{
+		maxConcurrency: defaultMaxConcurrency,
+	}
+	for _, f := range args {
+		f(&opts)
+	}
+
 	base, err := url.Parse(baseURL)
 	if err != nil {
 		return nil, err
 	}
 
+	transport := http.DefaultTransport
+	if opts.transport != nil {
+		transport = opts.transport
+	}
+	transport = retryTransport{next: transport, slots: make(chan struct{}, opts.maxConcurrency)}
+	if opts.cache != nil {
+		opts.cache.next = transport
+		transport = *opts.cache
+	}
+
 	return &Client{
-		username: user,
-		token:    token,
-		baseURL:  base,
-		client:   &http.Client{},
+		auth:    auth,
+		flavour: opts.flavour,
+		baseURL: base,
+		client:  &http.Client{Transport: transport},
+
+		since:        opts.since,
+		customFields: opts.customFields,
 	}, nil
 }
 
@@ -57,10 +164,7 @@ func (jc *Client) createRequest(ctx context.Context, method, path, body string)
 		return nil, err
 	}
 
-	// Add Basic Authentication
-	auth := jc.username + ":" + jc.token
-	encodedAuth := base64.StdEncoding.EncodeToString([]byte(auth))
-	req.Header.Add("Authorization", "Basic "+encodedAuth)
+	jc.auth.Authenticate(req)
 	req.Header.Add("Content-Type", "application/json")
 
 	return req, nil
@@ -79,7 +183,7 @@ func jiraGet[T any](ctx context.Context, jc *Client, path string, result *T) (er
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("got network status: %s", resp.Status)
+		return newAPIError(resp)
 	}
 
 	body, err := io.ReadAll(resp.Body)
@@ -94,14 +198,118 @@ func jiraGet[T any](ctx context.Context, jc *Client, path string, result *T) (er
 	return nil
 }
 
+// apiPath returns the REST API path for the client flavour.
+// Cloud uses the v3 API, with rich text as Atlassian Document Format, while Data Center only provides v2.
+func (jc *Client) apiPath(path string) string {
+	if jc.flavour == DataCenter {
+		return "/rest/api/2" + path
+	}
+	return "/rest/api/3" + path
+}
+
+// searchPath returns the JQL search endpoint.
+// Cloud moved to a token paginated endpoint in v3.
+func (jc *Client) searchPath() string {
+	if jc.flavour == DataCenter {
+		return jc.apiPath("/search")
+	}
+	return jc.apiPath("/search/jql")
+}
+
+// searchFields are the issue fields we always request in searches. The v3 search only returns issue IDs by default.
+// Comments, worklogs and changelog are embedded so that we only need extra requests when they are truncated.
+const (
+	searchFields = "summary,description,created,issuetype,status,parent,comment," +
+		"assignee,reporter,priority,labels,components,fixVersions,duedate,resolution,resolutiondate,issuelinks,worklog"
+	searchExpand = "changelog"
+)
+
+// fields returns the issue fields to request, with the configured custom fields.
+func (jc *Client) fields() string {
+	fields := searchFields
+	for _, id := range slices.Sorted(maps.Values(jc.customFields)) {
+		fields += "," + id
+	}
+	return fields
+}
+
+// pageSize is the number of elements we ask per page on paginated endpoints.
+// Jira may cap it to a lower value.
+const pageSize = 50
+
+// searchPage is one page of a JQL search.
+// Offset based endpoints fill StartAt, MaxResults and Total while the newer token based
+// endpoint only returns NextPageToken and IsLast.
+type searchPage struct {
+	StartAt       int
+	MaxResults    int
+	Total         int
+	IsLast        bool
+	NextPageToken string
+	// Issues are decoded separately, to only keep their raw fields when needed.
+	Issues []json.RawMessage
+}
+
+// searchIssues returns all issues matching the JQL query with the given fields and expansions, following pagination.
+// Issues are yielded as soon as each page arrives.
+func (jc *Client) searchIssues(ctx context.Context, jql, fields, expand string) iter.Seq2[jsonIssue, error] {
+	return func(yield func(jsonIssue, error) bool) {
+		var startAt int
+		var nextPageToken string
+		for {
+			params := url.Values{}
+			params.Set("jql", jql)
+			params.Set("fields", fields)
+			if expand != "" {
+				params.Set("expand", expand)
+			}
+			params.Set("maxResults", strconv.Itoa(pageSize))
+			switch {
+			case nextPageToken != "":
+				params.Set("nextPageToken", nextPageToken)
+			case startAt > 0:
+				params.Set("startAt", strconv.Itoa(startAt))
+			}
+
+			var page searchPage
+			if err := jiraGet(ctx, jc, jc.searchPath()+"?"+params.Encode(), &page); err != nil {
+				yield(jsonIssue{}, err)
+				return
+			}
+
+			for _, raw := range page.Issues {
+				issue, err := jc.decodeIssue(raw)
+				if err != nil {
+					yield(jsonIssue{}, err)
+					return
+				}
+				if more := yield(issue, nil); !more {
+					return
+				}
+			}
+
+			switch {
+			case page.NextPageToken != "":
+				nextPageToken = page.NextPageToken
+			case page.IsLast, len(page.Issues) == 0:
+				return
+			case page.StartAt+len(page.Issues) >= page.Total:
+				return
+			default:
+				startAt = page.StartAt + len(page.Issues)
+			}
+		}
+	}
+}
+
 // GetMyAssignedEpics retrieves all opened epics assigned to the current user and its children subtasks.
-func (jc *Client) GetMyAssignedEpics() iter.Seq2[Issue, error] {
+func (jc *Client) GetMyAssignedEpics(ctx context.Context) iter.Seq2[Issue, error] {
 	return func(yield func(Issue, error) bool) {
 		// Use JQL to find all epics assigned to the user that are NOT Done.
 		jql := "assignee = currentUser() AND issuetype = Epic AND status != Done"
-		for issue, err := range jc.getIssuesByJQL(context.Background(), jql) {
+		for issue, err := range jc.getIssuesByJQL(ctx, jql) {
 			if err != nil {
-				yield(Issue{}, fmt.Errorf("failed to retrieved current user's epics: %v", err))
+				yield(Issue{}, fmt.Errorf("failed to retrieved current user's epics: %w", err))
 				return
 			}
 			if more := yield(issue, nil); !more {
@@ -111,8 +319,13 @@ func (jc *Client) GetMyAssignedEpics() iter.Seq2[Issue, error] {
 	}
 }
 
+// issueKeyRE matches issue keys, like ABC-123, and numeric issue IDs.
+var issueKeyRE = regexp.MustCompile(`^([A-Z][A-Z0-9_]*-[0-9]+|[0-9]+)$`)
+
 // GetIssuesByKeys retrieves issues by their keys.
-func (jc *Client) GetIssuesByKeys(keys ...string) iter.Seq2[Issue, error] {
+// Keys which are invalid, missing or not visible to the user don't stop the iteration: a *KeyError is yielded
+// for each of them before continuing with the other keys.
+func (jc *Client) GetIssuesByKeys(ctx context.Context, keys ...string) iter.Seq2[Issue, error] {
 	return func(yield func(Issue, error) bool) {
 
 		//defer decorate.OnError(&err, "failed to retrieved issues from given keys: %s", strings.Join(keys, ", "))
@@ -122,12 +335,78 @@ func (jc *Client) GetIssuesByKeys(keys ...string) iter.Seq2[Issue, error] {
 			return
 		}
 
-		jql := fmt.Sprintf("key in (%s)", strings.Join(keys, ","))
-		for issue, err := range jc.getIssuesByJQL(context.Background(), jql) {
+		var validKeys []string
+		for _, key := range keys {
+			key = strings.ToUpper(strings.TrimSpace(key))
+			if !issueKeyRE.MatchString(key) {
+				if more := yield(Issue{}, &KeyError{Key: key, Err: errors.New("invalid issue key")}); !more {
+					return
+				}
+				continue
+			}
+			if slices.Contains(validKeys, key) {
+				continue
+			}
+			validKeys = append(validKeys, key)
+		}
+		if len(validKeys) == 0 {
+			return
+		}
+
+		found := make(map[string]bool)
+		jql := fmt.Sprintf("key in (%s)", strings.Join(validKeys, ","))
+		for issue, err := range jc.getIssuesByJQL(ctx, jql) {
 			if err != nil {
-				yield(Issue{}, fmt.Errorf("ailed to retrieved issues from given keys (%s): %v", strings.Join(keys, ", "), err))
+				// Jira rejects the whole query when one of the keys does not exist: we will retry them one by one.
+				if len(found) == 0 && isKeyError(err) {
+					slog.Debug(fmt.Sprintf("retrying issue keys one by one: %v", err))
+					break
+				}
+				yield(Issue{}, fmt.Errorf("failed to retrieved issues from given keys (%s): %w", strings.Join(validKeys, ", "), err))
 				return
 			}
+			// The issue was found by a key it had before being moved, which is older than its recent changes.
+			if !knownAs(issue, validKeys) {
+				if err := issue.fetchFormerKeys(ctx, jc, validKeys); err != nil {
+					yield(Issue{}, err)
+					return
+				}
+			}
+			markFound(found, issue)
+			if more := yield(issue, nil); !more {
+				return
+			}
+		}
+
+		// Missing keys can be former keys or IDs of issues we already have, or keys of restricted issues,
+		// which are silently omitted from searches: resolve them one by one to report them.
+		for _, key := range validKeys {
+			if found[key] {
+				continue
+			}
+
+			currentKey, id, err := jc.resolveKey(ctx, key)
+			if err == nil && (found[currentKey] || found[id]) {
+				slog.Debug(fmt.Sprintf("issue %s is already retrieved as %s", key, currentKey))
+				continue
+			}
+
+			var issue Issue
+			if err == nil {
+				issue, err = jc.GetIssue(ctx, key)
+			}
+			if err != nil {
+				if !isKeyError(err) {
+					yield(Issue{}, err)
+					return
+				}
+				if more := yield(Issue{}, &KeyError{Key: key, Err: err}); !more {
+					return
+				}
+				continue
+			}
+
+			markFound(found, issue)
 			if more := yield(issue, nil); !more {
 				return
 			}
@@ -135,12 +414,30 @@ func (jc *Client) GetIssuesByKeys(keys ...string) iter.Seq2[Issue, error] {
 	}
 }
 
+// markFound records all keys and ID the issue can be requested with.
+func markFound(found map[string]bool, issue Issue) {
+	found[issue.Key] = true
+	if issue.ID != "" {
+		found[issue.ID] = true
+	}
+	for _, k := range issue.FormerKeys {
+		found[k] = true
+	}
+}
+
+// knownAs returns if any of the keys is the key, the ID or a former key of the issue.
+func knownAs(issue Issue, keys []string) bool {
+	return slices.ContainsFunc(keys, func(k string) bool {
+		return k == issue.Key || k == issue.ID || slices.Contains(issue.FormerKeys, k)
+	})
+}
+
 func (jc *Client) getIssuesByJQL(ctx context.Context, jql string) iter.Seq2[Issue, error] {
 	return func(yield func(Issue, error) bool) {
 		var iterErr error
 		defer func() {
 			if iterErr != nil {
-				yield(Issue{}, fmt.Errorf("failed to retrieved issues from JQL: %v", iterErr))
+				yield(Issue{}, fmt.Errorf("failed to retrieved issues from JQL: %w", iterErr))
 				return
 			}
 		}()
@@ -150,39 +447,42 @@ func (jc *Client) getIssuesByJQL(ctx context.Context, jql string) iter.Seq2[Issu
 			return
 		}
 
-		encodedJQL := url.QueryEscape(jql)
-		path := fmt.Sprintf("/rest/api/2/search?jql=%s", encodedJQL)
-
-		var result struct {
-			Issues []jsonIssue
-		}
-		if err := jiraGet(ctx, jc, path, &result); err != nil {
-			iterErr = err
-			return
-		}
-
-		topIssuesCtx, topIssuesCancel := context.WithCancel(context.Background())
+		topIssuesCtx, topIssuesCancel := context.WithCancel(ctx)
 		defer topIssuesCancel()
 
-		var g errgroup.Group
 		var issueCh = make(chan Issue)
-		for _, jIssue := range result.Issues {
-			g.Go(func() error {
-				// Each top issue is processed independently of others.
-				i, err := newIssueFromJsonIssue(topIssuesCtx, jIssue, jc)
+		var goroutinesErr error
+		go func() {
+			defer close(issueCh)
+
+			var g errgroup.Group
+			var searchErr error
+			for jIssue, err := range jc.searchIssues(topIssuesCtx, jql, jc.fields(), searchExpand) {
 				if err != nil {
-					return err
+					searchErr = issueRequestError{err}
+					break
 				}
 
-				issueCh <- i
-				return nil
-			})
-		}
+				g.Go(func() error {
+					// Each top issue is processed independently of others.
+					i, err := newIssueFromJsonIssue(topIssuesCtx, jIssue, jc)
+					if err != nil {
+						return err
+					}
+
+					select {
+					case issueCh <- i:
+					case <-topIssuesCtx.Done():
+						return topIssuesCtx.Err()
+					}
+					return nil
+				})
+			}
 
-		var goroutinesErr error
-		go func() {
 			goroutinesErr = g.Wait()
-			close(issueCh)
+			if searchErr != nil {
+				goroutinesErr = searchErr
+			}
 		}()
 
 		// Propagating issues or errors.
@@ -206,15 +506,17 @@ func (jc *Client) getIssuesByJQL(ctx context.Context, jql string) iter.Seq2[Issu
 }
 
 // GetIssue retrieves a given issue and children subtasks assigned to it.
-func (jc *Client) GetIssue(key string) (issue Issue, err error) {
+func (jc *Client) GetIssue(ctx context.Context, key string) (issue Issue, err error) {
 	defer decorate.OnError(&err, "failed to retrieved issue %s", key)
 
-	path := fmt.Sprintf("/rest/api/2/issue/%s", key)
-
-	ctx := context.Background()
+	path := jc.apiPath(fmt.Sprintf("/issue/%s?fields=%s&expand=%s", key, jc.fields(), searchExpand))
 
-	var jIssue jsonIssue
-	if err := jiraGet(ctx, jc, path, &jIssue); err != nil {
+	var raw json.RawMessage
+	if err := jiraGet(ctx, jc, path, &raw); err != nil {
+		return Issue{}, issueRequestError{err}
+	}
+	jIssue, err := jc.decodeIssue(raw)
+	if err != nil {
 		return Issue{}, err
 	}
 
@@ -223,5 +525,62 @@ func (jc *Client) GetIssue(key string) (issue Issue, err error) {
 		return Issue{}, err
 	}
 
+	// Jira follows moved issues: record the key we were asked for if the changelog did not have it.
+	key = strings.ToUpper(key)
+	if key != i.Key && key != i.ID && !slices.Contains(i.FormerKeys, key) {
+		i.FormerKeys = append(i.FormerKeys, key)
+	}
+
 	return i, nil
 }
+
+// AddComment adds a Markdown comment to the issue with the given key.
+func (jc *Client) AddComment(ctx context.Context, key, commentBody string) (err error) {
+	defer decorate.OnError(&err, "failed to add comment on issue %s", key)
+
+	path := jc.apiPath(fmt.Sprintf("/issue/%s/comment", key))
+
+	// v3 API expects rich text as an ADF document.
+	var body any = commentBody
+	if jc.flavour != DataCenter {
+		body = adf.FromMarkdown(commentBody)
+	}
+	d, err := json.Marshal(struct {
+		Body any `json:"body"`
+	}{body})
+	if err != nil {
+		return err
+	}
+
+	req, err := jc.createRequest(ctx, "POST", path, string(d))
+	if err != nil {
+		return err
+	}
+
+	resp, err := jc.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return newAPIError(resp)
+	}
+
+	return nil
+}
+
+// resolveKey returns the current key and ID of an issue from any of its current or former keys, or its ID.
+func (jc *Client) resolveKey(ctx context.Context, key string) (currentKey, id string, err error) {
+	defer decorate.OnError(&err, "failed to resolve issue %s", key)
+
+	var jIssue struct {
+		ID  string
+		Key string
+	}
+	if err := jiraGet(ctx, jc, jc.apiPath(fmt.Sprintf("/issue/%s?fields=issuetype", key)), &jIssue); err != nil {
+		return "", "", issueRequestError{err}
+	}
+
+	return jIssue.Key, jIssue.ID, nil
+}
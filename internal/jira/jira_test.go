@@ -0,0 +1,745 @@
+package jira_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/canonical/jira-summarizer/internal/jira"
+	"github.com/canonical/jira-summarizer/internal/jira/jiratest"
+	"github.com/canonical/jira-summarizer/internal/testutils"
+)
+
+// day is the reference time of the test issues.
+var day = testutils.Day
+
+func TestGetMyAssignedEpics(t *testing.T) {
+	t.Parallel()
+
+	issues := []jiratest.Issue{
+		{
+			Key: "EPIC-1", IssueType: "Epic", Status: "In Progress", Assignee: jiratest.CurrentUser,
+			Summary: "Assigned epic", Description: "Some **context**", Created: day.AddDate(0, -1, 0),
+			Comments: []jiratest.Comment{{Author: "Alice", Created: day, Body: "Looking good"}},
+			Changelog: []jiratest.ChangeSet{
+				{Author: "Bob", Created: day.Add(-time.Hour), Items: []jiratest.ChangeItem{{Field: "status", From: "To Do", To: "In Progress"}}},
+			},
+		},
+		{Key: "EPIC-2", IssueType: "Epic", Status: "Done", Assignee: jiratest.CurrentUser, Created: day},
+		{Key: "EPIC-3", IssueType: "Epic", Status: "In Progress", Assignee: "Someone else", Created: day},
+		{Key: "TASK-1", IssueType: "Task", Status: "To Do", Parent: "EPIC-1", Created: day},
+		{Key: "SUB-1", IssueType: "Sub-task", SubTask: true, Status: "To Do", Parent: "TASK-1", Created: day},
+		{Key: "TASK-2", IssueType: "Task", Status: "To Do", Parent: "EPIC-3", Created: day},
+	}
+
+	tests := map[string]struct {
+		flavour jira.Flavour
+	}{
+		"Cloud":       {flavour: jira.Cloud},
+		"Data Center": {flavour: jira.DataCenter},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			srv := jiratest.NewServer(t, issues)
+			jc := srv.Client(t, jira.WithFlavour(tc.flavour))
+
+			got := collect(t, jc.GetMyAssignedEpics(context.Background()))
+
+			if tree(got) != "EPIC-1(TASK-1(SUB-1))" {
+				t.Fatalf("GetMyAssignedEpics returned %s, want EPIC-1(TASK-1(SUB-1))", tree(got))
+			}
+			epic := got[0]
+			if epic.Description != "Some **context**" {
+				t.Errorf("Description = %q, want %q", epic.Description, "Some **context**")
+			}
+			if epic.URL != srv.URL+"/browse/EPIC-1" {
+				t.Errorf("URL = %q, want %q", epic.URL, srv.URL+"/browse/EPIC-1")
+			}
+			if epic.Status.Name != "In Progress" || epic.Status.Who != "Bob" || !epic.Status.When.Equal(day.Add(-time.Hour)) {
+				t.Errorf("Status = %+v, want In Progress by Bob on %s", epic.Status, day.Add(-time.Hour))
+			}
+			if len(epic.Comments) != 1 || epic.Comments[0].Content != "Looking good" || epic.Comments[0].Who != "Alice" {
+				t.Errorf("Comments = %+v, want one comment from Alice", epic.Comments)
+			}
+		})
+	}
+}
+
+func TestPagination(t *testing.T) {
+	t.Parallel()
+
+	epic := jiratest.Issue{Key: "EPIC-1", IssueType: "Epic", Status: "Done", Created: day}
+	var want []string
+	for n := range 5 {
+		epic.Comments = append(epic.Comments, jiratest.Comment{Author: "Alice", Created: day.Add(time.Duration(n) * time.Hour), Body: fmt.Sprintf("Comment %d", n)})
+		epic.Changelog = append(epic.Changelog, jiratest.ChangeSet{
+			Author:  fmt.Sprintf("User %d", n),
+			Created: day.Add(time.Duration(n) * time.Hour),
+			Items:   []jiratest.ChangeItem{{Field: "status", From: "To Do", To: "Done"}},
+		})
+		want = append(want, fmt.Sprintf("Comment %d", n))
+	}
+	issues := []jiratest.Issue{epic}
+	for n := range 5 {
+		issues = append(issues, jiratest.Issue{Key: fmt.Sprintf("TASK-%d", n), IssueType: "Task", Parent: "EPIC-1", Created: day})
+	}
+
+	for _, flavour := range []jira.Flavour{jira.Cloud, jira.DataCenter} {
+		t.Run(flavour.String(), func(t *testing.T) {
+			t.Parallel()
+
+			srv := jiratest.NewServer(t, issues, jiratest.WithPageSize(2), jiratest.WithEmbeddedLimit(1))
+			jc := srv.Client(t, jira.WithFlavour(flavour))
+
+			got := collect(t, jc.GetIssuesByKeys(context.Background(), "EPIC-1"))
+
+			if tree(got) != "EPIC-1(TASK-0,TASK-1,TASK-2,TASK-3,TASK-4)" {
+				t.Errorf("Got %s, want all children of EPIC-1", tree(got))
+			}
+			var comments []string
+			for _, c := range got[0].Comments {
+				comments = append(comments, c.Content)
+			}
+			if !slices.Equal(comments, want) {
+				t.Errorf("Comments = %q, want %q", comments, want)
+			}
+			if got[0].Status.Who != "User 4" {
+				t.Errorf("Status changed by %q, want the most recent change by %q", got[0].Status.Who, "User 4")
+			}
+		})
+	}
+}
+
+func TestGetIssuesByKeys(t *testing.T) {
+	t.Parallel()
+
+	srv := jiratest.NewServer(t, []jiratest.Issue{
+		{Key: "PROJ-1", IssueType: "Epic", Created: day},
+		{Key: "NEW-2", FormerKeys: []string{"PROJ-2"}, IssueType: "Epic", Created: day},
+		{Key: "PROJ-3", IssueType: "Epic", Created: day, Restricted: true},
+	})
+	jc := srv.Client(t)
+
+	var got []jira.Issue
+	var keyErrs []string
+	for issue, err := range jc.GetIssuesByKeys(context.Background(), "proj-1", "PROJ-2", "PROJ-3", "PROJ-404", "not a key", "PROJ-1") {
+		var keyErr *jira.KeyError
+		if errors.As(err, &keyErr) {
+			keyErrs = append(keyErrs, keyErr.Key)
+			continue
+		}
+		if err != nil {
+			t.Fatalf("GetIssuesByKeys returned an unexpected error: %v", err)
+		}
+		got = append(got, issue)
+	}
+
+	if tree(got) != "PROJ-1,NEW-2" {
+		t.Errorf("Got issues %s, want PROJ-1,NEW-2", tree(got))
+	}
+	if len(got) == 2 && !slices.Contains(got[1].FormerKeys, "PROJ-2") {
+		t.Errorf("Former keys of NEW-2 = %q, want PROJ-2 in it", got[1].FormerKeys)
+	}
+	slices.Sort(keyErrs)
+	if want := []string{"NOT A KEY", "PROJ-3", "PROJ-404"}; !slices.Equal(keyErrs, want) {
+		t.Errorf("Got key errors for %q, want %q", keyErrs, want)
+	}
+}
+
+func TestGetIssuesByKeysDescendantErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		failPattern string
+		failStatus  int
+
+		wantErr error
+	}{
+		"Error on forbidden comments of a child":  {failPattern: "/issue/TASK-1/comment", failStatus: http.StatusForbidden, wantErr: jira.ErrForbidden},
+		"Error on missing worklogs of a child":    {failPattern: "/issue/TASK-1/worklog", failStatus: http.StatusNotFound, wantErr: jira.ErrNotFound},
+		"Error on invalid changelog of a child":   {failPattern: "/issue/TASK-1/changelog", failStatus: http.StatusBadRequest, wantErr: &jira.APIError{}},
+		"Error on forbidden comments of an issue": {failPattern: "/issue/PROJ-1/comment", failStatus: http.StatusForbidden, wantErr: jira.ErrForbidden},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			srv := jiratest.NewServer(t, []jiratest.Issue{
+				{Key: "PROJ-1", IssueType: "Epic", Created: day, Comments: []jiratest.Comment{{Author: "Alice", Created: day, Body: "Hello"}}},
+				{Key: "TASK-1", IssueType: "Task", Parent: "PROJ-1", Created: day,
+					Comments:  []jiratest.Comment{{Author: "Alice", Created: day, Body: "Hello"}},
+					Worklogs:  []jiratest.Worklog{{Author: "Alice", Started: day, TimeSpent: time.Hour}},
+					Changelog: []jiratest.ChangeSet{{Author: "Bob", Created: day, Items: []jiratest.ChangeItem{{Field: "status", From: "To Do", To: "Done"}}}}},
+			}, jiratest.WithEmbeddedLimit(0))
+			srv.Fail(tc.failPattern, tc.failStatus, 100)
+			jc := srv.Client(t)
+
+			var gotErr error
+			for _, err := range jc.GetIssuesByKeys(context.Background(), "PROJ-1") {
+				if err != nil {
+					gotErr = err
+					break
+				}
+			}
+
+			// Failures of additional requests are not due to the requested keys.
+			var keyErr *jira.KeyError
+			if errors.As(gotErr, &keyErr) {
+				t.Fatalf("Got key error %v, want a fatal error", gotErr)
+			}
+			var apiErr *jira.APIError
+			switch {
+			case gotErr == nil:
+				t.Fatal("GetIssuesByKeys should have failed but didn't")
+			case errors.As(tc.wantErr, &apiErr):
+				if !errors.As(gotErr, &apiErr) {
+					t.Fatalf("Got error %v, want an API error", gotErr)
+				}
+			case !errors.Is(gotErr, tc.wantErr):
+				t.Fatalf("Got error %v, want %v", gotErr, tc.wantErr)
+			}
+			for _, r := range srv.Requests() {
+				if strings.Contains(r, "/issue/PROJ-1?") {
+					t.Errorf("Valid keys should not be retried one by one, got request %s", r)
+				}
+			}
+		})
+	}
+}
+
+func TestFormerKeys(t *testing.T) {
+	t.Parallel()
+
+	since := day.AddDate(0, 0, -7)
+	move := func(when time.Time, from, to string) jiratest.ChangeSet {
+		return jiratest.ChangeSet{Author: "Alice", Created: when, Items: []jiratest.ChangeItem{{Field: "Key", From: from, To: to}}}
+	}
+	issue := jiratest.Issue{Key: "NEW-1", FormerKeys: []string{"OLDER-1", "OLD-1"}, IssueType: "Task", Created: day.AddDate(0, -2, 0),
+		Changelog: []jiratest.ChangeSet{
+			move(day.AddDate(0, -2, 0), "OLDER-1", "OLD-1"),
+			{Author: "Bob", Created: day.AddDate(0, -1, 0), Items: []jiratest.ChangeItem{{Field: "summary", From: "Old", To: "New"}}},
+			move(since.AddDate(0, 0, -1), "OLD-1", "NEW-1"),
+			{Author: "Bob", Created: day, Items: []jiratest.ChangeItem{{Field: "priority", From: "Low", To: "High"}}},
+			{Author: "Bob", Created: day.Add(time.Hour), Items: []jiratest.ChangeItem{{Field: "summary", From: "New", To: "Newer"}}},
+		}}
+
+	tests := map[string]struct {
+		key     string
+		flavour jira.Flavour
+
+		want []string
+		// wantNoRequest is a request which should not be sent, to stop walking the changelog early.
+		wantNoRequest string
+	}{
+		"Stops at the window when requested by the current key": {key: "NEW-1", wantNoRequest: "/changelog?startAt=1&"},
+		"Walks back to the requested former key":                {key: "OLD-1", want: []string{"OLD-1"}, wantNoRequest: "/changelog?startAt=1&"},
+		"Walks back to the oldest requested former key":         {key: "OLDER-1", want: []string{"OLDER-1", "OLD-1"}},
+
+		"Data Center stops at the window when requested by the current key": {key: "NEW-1", flavour: jira.DataCenter, wantNoRequest: "/issue/NEW-1"},
+		"Data Center walks back to the requested former key":                {key: "OLDER-1", flavour: jira.DataCenter, want: []string{"OLDER-1", "OLD-1"}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			srv := jiratest.NewServer(t, []jiratest.Issue{issue}, jiratest.WithEmbeddedLimit(1), jiratest.WithPageSize(1))
+			jc := srv.Client(t, jira.WithFlavour(tc.flavour), jira.WithSince(since))
+
+			got := collect(t, jc.GetIssuesByKeys(context.Background(), tc.key))
+
+			if len(got) != 1 {
+				t.Fatalf("GetIssuesByKeys returned %d issues, want 1", len(got))
+			}
+			if !slices.Equal(got[0].FormerKeys, tc.want) {
+				t.Errorf("Former keys = %q, want %q", got[0].FormerKeys, tc.want)
+			}
+			if len(got[0].Changes) != 2 {
+				t.Errorf("Changes = %+v, want only the 2 in the window", got[0].Changes)
+			}
+			if tc.wantNoRequest == "" {
+				return
+			}
+			for _, r := range srv.Requests() {
+				if strings.Contains(r, tc.wantNoRequest) {
+					t.Errorf("Changelog should not be walked past the window, got request %q", r)
+				}
+			}
+		})
+	}
+}
+
+func TestSinceWindow(t *testing.T) {
+	t.Parallel()
+
+	old := day.AddDate(0, -2, 0)
+	issues := []jiratest.Issue{
+		{Key: "OBJ-1", IssueType: "Objective", Created: old},
+		{Key: "EPIC-1", IssueType: "Epic", Summary: "Stale epic", Parent: "OBJ-1", Created: old},
+		{Key: "EPIC-2", IssueType: "Epic", Parent: "OBJ-1", Created: old, Comments: []jiratest.Comment{
+			{Author: "Alice", Created: old, Body: "Old comment"},
+		}},
+		{Key: "TASK-1", IssueType: "Task", Parent: "EPIC-1", Created: old, Comments: []jiratest.Comment{
+			{Author: "Alice", Created: old, Body: "Old comment"},
+			{Author: "Alice", Created: day, Body: "Recent comment"},
+		}},
+	}
+
+	// Data Center links tasks to their epic through the Epic Link field instead.
+	for _, flavour := range []jira.Flavour{jira.Cloud, jira.DataCenter} {
+		t.Run(flavour.String(), func(t *testing.T) {
+			t.Parallel()
+
+			srv := jiratest.NewServer(t, issues, jiratest.WithEmbeddedLimit(0))
+			jc := srv.Client(t, jira.WithFlavour(flavour), jira.WithSince(day.AddDate(0, 0, -7)))
+
+			got := collect(t, jc.GetIssuesByKeys(context.Background(), "OBJ-1"))
+
+			// EPIC-2 is stale, without recent descendants.
+			if tree(got) != "OBJ-1(EPIC-1(TASK-1))" {
+				t.Fatalf("Got %s, want OBJ-1(EPIC-1(TASK-1))", tree(got))
+			}
+			comments := got[0].Children[0].Children[0].Comments
+			if len(comments) != 1 || comments[0].Content != "Recent comment" {
+				t.Errorf("Comments = %+v, want only the recent one", comments)
+			}
+			if got[0].Children[0].Summary != "Stale epic" {
+				t.Errorf("Summary of EPIC-1 = %q, want the full stale parent of a recent issue", got[0].Children[0].Summary)
+			}
+			for _, r := range srv.Requests() {
+				if strings.Contains(r, "/issue/EPIC-2") || strings.Contains(r, "key+in") && strings.Contains(r, "EPIC-2") {
+					t.Errorf("Stale EPIC-2 without recent descendants should only be searched, got request %s", r)
+				}
+			}
+		})
+	}
+}
+
+func TestStatusTransitions(t *testing.T) {
+	t.Parallel()
+
+	since := day.AddDate(0, 0, -7)
+	status := func(who string, when time.Time, from, to string) jiratest.ChangeSet {
+		return jiratest.ChangeSet{Author: who, Created: when, Items: []jiratest.ChangeItem{{Field: "status", From: from, To: to}}}
+	}
+	srv := jiratest.NewServer(t, []jiratest.Issue{
+		{Key: "TASK-1", IssueType: "Task", Status: "In Progress", Created: day.AddDate(0, -1, 0), Changelog: []jiratest.ChangeSet{
+			status("Alice", since.AddDate(0, 0, -1), "To Do", "In Progress"),
+			status("Bob", day.Add(time.Hour), "In Progress", "Blocked"),
+			{Author: "Bob", Created: day.Add(2 * time.Hour), Items: []jiratest.ChangeItem{{Field: "summary", From: "Old", To: "New"}}},
+			status("Carol", day.Add(3*time.Hour), "Blocked", "In Progress"),
+		}},
+	})
+
+	for _, flavour := range []jira.Flavour{jira.Cloud, jira.DataCenter} {
+		t.Run(flavour.String(), func(t *testing.T) {
+			t.Parallel()
+
+			jc := srv.Client(t, jira.WithFlavour(flavour), jira.WithSince(since))
+
+			got := collect(t, jc.GetIssuesByKeys(context.Background(), "TASK-1"))
+
+			want := []jira.Transition{
+				{From: "In Progress", To: "Blocked", Who: "Bob", When: day.Add(time.Hour)},
+				{From: "Blocked", To: "In Progress", Who: "Carol", When: day.Add(3 * time.Hour)},
+			}
+			if !slices.EqualFunc(got[0].Transitions, want, func(a, b jira.Transition) bool {
+				return a.From == b.From && a.To == b.To && a.Who == b.Who && a.When.Equal(b.When)
+			}) {
+				t.Errorf("Transitions = %+v, want %+v", got[0].Transitions, want)
+			}
+			if got[0].Status.Who != "Carol" {
+				t.Errorf("Status changed by %q, want the latest transition by Carol", got[0].Status.Who)
+			}
+		})
+	}
+}
+
+func TestFieldChanges(t *testing.T) {
+	t.Parallel()
+
+	since := day.AddDate(0, 0, -7)
+	srv := jiratest.NewServer(t, []jiratest.Issue{
+		{Key: "TASK-1", IssueType: "Task", Status: "In Progress", Created: day.AddDate(0, -1, 0), Changelog: []jiratest.ChangeSet{
+			{Author: "Alice", Created: since.AddDate(0, 0, -1), Items: []jiratest.ChangeItem{{Field: "priority", From: "Low", To: "Medium"}}},
+			{Author: "Bob", Created: day, Items: []jiratest.ChangeItem{
+				{Field: "priority", From: "Medium", To: "High"},
+				{Field: "status", From: "To Do", To: "In Progress"},
+			}},
+			{Author: "Carol", Created: day.Add(time.Hour), Items: []jiratest.ChangeItem{{Field: "assignee", To: "Carol"}}},
+		}},
+	})
+
+	for _, flavour := range []jira.Flavour{jira.Cloud, jira.DataCenter} {
+		t.Run(flavour.String(), func(t *testing.T) {
+			t.Parallel()
+
+			jc := srv.Client(t, jira.WithFlavour(flavour), jira.WithSince(since))
+
+			got := collect(t, jc.GetIssuesByKeys(context.Background(), "TASK-1"))
+
+			want := []jira.Change{
+				{Field: "priority", From: "Medium", To: "High", Who: "Bob", When: day},
+				{Field: "assignee", To: "Carol", Who: "Carol", When: day.Add(time.Hour)},
+			}
+			if !slices.EqualFunc(got[0].Changes, want, func(a, b jira.Change) bool {
+				return a.Field == b.Field && a.From == b.From && a.To == b.To && a.Who == b.Who && a.When.Equal(b.When)
+			}) {
+				t.Errorf("Changes = %+v, want %+v", got[0].Changes, want)
+			}
+		})
+	}
+}
+
+func TestLinks(t *testing.T) {
+	t.Parallel()
+
+	since := day.AddDate(0, 0, -7)
+	srv := jiratest.NewServer(t, []jiratest.Issue{
+		{Key: "TASK-1", IssueType: "Task", Created: day,
+			Links: []jiratest.Link{{Relation: "is blocked by", Key: "OPS-1"}, {Relation: "blocks", Key: "OPS-2"}},
+			Changelog: []jiratest.ChangeSet{
+				{Author: "Alice", Created: since.AddDate(0, 0, -1), Items: []jiratest.ChangeItem{{Field: "Link", To: "This issue blocks OPS-2"}}},
+				{Author: "Bob", Created: day, Items: []jiratest.ChangeItem{
+					{Field: "Link", To: "This issue is blocked by OPS-1"},
+					{Field: "Link", From: "This issue relates to OPS-3"},
+				}},
+			}},
+		{Key: "OPS-1", IssueType: "Task", Summary: "Provision servers", Status: "In Progress", Created: day},
+		{Key: "OPS-2", IssueType: "Task", Summary: "Deploy", Status: "Done", Created: day},
+	})
+
+	for _, flavour := range []jira.Flavour{jira.Cloud, jira.DataCenter} {
+		t.Run(flavour.String(), func(t *testing.T) {
+			t.Parallel()
+
+			jc := srv.Client(t, jira.WithFlavour(flavour), jira.WithSince(since))
+
+			got := collect(t, jc.GetIssuesByKeys(context.Background(), "TASK-1"))
+
+			wantLinks := []jira.Link{
+				{Relation: "is blocked by", Key: "OPS-1", Summary: "Provision servers", Status: "In Progress"},
+				{Relation: "blocks", Key: "OPS-2", Summary: "Deploy", Status: "Done", Done: true},
+			}
+			if !slices.Equal(got[0].Links, wantLinks) {
+				t.Errorf("Links = %+v, want %+v", got[0].Links, wantLinks)
+			}
+			if blockers := got[0].OpenBlockers(); !slices.Equal(blockers, wantLinks[:1]) {
+				t.Errorf("OpenBlockers = %+v, want %+v", blockers, wantLinks[:1])
+			}
+			if got[0].Done {
+				t.Error("TASK-1 should not be done")
+			}
+			if blocked := collect(t, jc.GetIssuesByKeys(context.Background(), "OPS-2")); !blocked[0].Done {
+				t.Error("OPS-2 should be done")
+			}
+
+			wantChanges := []jira.LinkChange{
+				{Description: "This issue is blocked by OPS-1", Key: "OPS-1", Who: "Bob", When: day},
+				{Description: "This issue relates to OPS-3", Key: "OPS-3", Removed: true, Who: "Bob", When: day},
+			}
+			if !slices.EqualFunc(got[0].LinkChanges, wantChanges, func(a, b jira.LinkChange) bool {
+				return a.Description == b.Description && a.Key == b.Key && a.Removed == b.Removed && a.Who == b.Who && a.When.Equal(b.When)
+			}) {
+				t.Errorf("LinkChanges = %+v, want %+v", got[0].LinkChanges, wantChanges)
+			}
+		})
+	}
+}
+
+func TestWorklogs(t *testing.T) {
+	t.Parallel()
+
+	since := day.AddDate(0, 0, -7)
+	worklogs := []jiratest.Worklog{
+		{Author: "Alice", Started: since.AddDate(0, 0, -1), TimeSpent: time.Hour, Comment: "Before the window"},
+		{Author: "Bob", Started: day.Add(2 * time.Hour), TimeSpent: 30 * time.Minute},
+		// Logged after the previous one, for earlier work.
+		{Author: "Alice", Started: day, TimeSpent: 2 * time.Hour, Comment: "Investigation"},
+	}
+	want := []jira.Worklog{
+		{Who: "Alice", Started: day, TimeSpent: 2 * time.Hour, Comment: "Investigation"},
+		{Who: "Bob", Started: day.Add(2 * time.Hour), TimeSpent: 30 * time.Minute},
+	}
+
+	tests := map[string]struct {
+		flavour       jira.Flavour
+		embeddedLimit int
+	}{
+		"Cloud embedded worklogs":        {flavour: jira.Cloud},
+		"Data Center embedded worklogs":  {flavour: jira.DataCenter},
+		"Cloud truncated worklogs":       {flavour: jira.Cloud, embeddedLimit: 1},
+		"Data Center truncated worklogs": {flavour: jira.DataCenter, embeddedLimit: 1},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var opts []jiratest.Option
+			if tc.embeddedLimit > 0 {
+				opts = append(opts, jiratest.WithEmbeddedLimit(tc.embeddedLimit), jiratest.WithPageSize(tc.embeddedLimit))
+			}
+			srv := jiratest.NewServer(t, []jiratest.Issue{{Key: "TASK-1", IssueType: "Task", Created: day, Worklogs: worklogs}}, opts...)
+			jc := srv.Client(t, jira.WithFlavour(tc.flavour), jira.WithSince(since))
+
+			got := collect(t, jc.GetIssuesByKeys(context.Background(), "TASK-1"))
+
+			if !slices.EqualFunc(got[0].Worklogs, want, func(a, b jira.Worklog) bool {
+				return a.Who == b.Who && a.Started.Equal(b.Started) && a.TimeSpent == b.TimeSpent && a.Comment == b.Comment
+			}) {
+				t.Errorf("Worklogs = %+v, want %+v", got[0].Worklogs, want)
+			}
+			if spent := got[0].TimeSpent(); spent != 150*time.Minute {
+				t.Errorf("TimeSpent = %s, want 2h30m", spent)
+			}
+			fetched := slices.ContainsFunc(srv.Requests(), func(r string) bool { return strings.Contains(r, "/worklog") })
+			if wantFetched := tc.embeddedLimit > 0; fetched != wantFetched {
+				t.Errorf("Worklogs fetched separately: %v, want %v", fetched, wantFetched)
+			}
+		})
+	}
+}
+
+func TestErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		failPattern string
+		failStatus  int
+		failCount   int
+		token       string
+
+		wantErr error
+	}{
+		"Retries rate limited requests":                {failPattern: "/search", failStatus: http.StatusTooManyRequests, failCount: 2},
+		"Retries requests when service is unavailable": {failPattern: "/search", failStatus: http.StatusServiceUnavailable, failCount: 2},
+
+		"Error when still rate limited after all retries": {failPattern: "/search", failStatus: http.StatusTooManyRequests, failCount: 100, wantErr: jira.ErrRateLimited},
+		"Error on server failure":                         {failPattern: "/comment", failStatus: http.StatusInternalServerError, failCount: 1, wantErr: &jira.APIError{}},
+		"Error on invalid credentials":                    {token: "invalid", wantErr: jira.ErrUnauthorized},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			srv := jiratest.NewServer(t, []jiratest.Issue{
+				{Key: "EPIC-1", IssueType: "Epic", Assignee: jiratest.CurrentUser, Created: day,
+					Comments: []jiratest.Comment{{Author: "Alice", Created: day, Body: "Hello"}}},
+			}, jiratest.WithEmbeddedLimit(0))
+			if tc.failPattern != "" {
+				srv.Fail(tc.failPattern, tc.failStatus, tc.failCount)
+			}
+			token := jiratest.Token
+			if tc.token != "" {
+				token = tc.token
+			}
+			jc, err := jira.NewClient(srv.URL, jira.BasicAuth{Username: jiratest.Username, Token: token})
+			if err != nil {
+				t.Fatalf("Setup: failed to create client: %v", err)
+			}
+
+			var gotErr error
+			var got []jira.Issue
+			for issue, err := range jc.GetMyAssignedEpics(context.Background()) {
+				if err != nil {
+					gotErr = err
+					break
+				}
+				got = append(got, issue)
+			}
+
+			var apiErr *jira.APIError
+			switch {
+			case tc.wantErr == nil && gotErr != nil:
+				t.Fatalf("GetMyAssignedEpics returned an unexpected error: %v", gotErr)
+			case tc.wantErr == nil && tree(got) != "EPIC-1":
+				t.Fatalf("Got %s, want EPIC-1", tree(got))
+			case tc.wantErr == nil:
+			case errors.As(tc.wantErr, &apiErr):
+				if !errors.As(gotErr, &apiErr) {
+					t.Fatalf("Got error %v, want an API error", gotErr)
+				}
+			case !errors.Is(gotErr, tc.wantErr):
+				t.Fatalf("Got error %v, want %v", gotErr, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestRetriesReleaseRequestSlots(t *testing.T) {
+	t.Parallel()
+
+	srv := jiratest.NewServer(t, []jiratest.Issue{
+		{Key: "TASK-1", IssueType: "Task", Created: day,
+			Comments:  []jiratest.Comment{{Author: "Alice", Created: day, Body: "Hello"}},
+			Changelog: []jiratest.ChangeSet{{Author: "Bob", Created: day, Items: []jiratest.ChangeItem{{Field: "summary", From: "Old", To: "New"}}}},
+		},
+	}, jiratest.WithEmbeddedLimit(0))
+	srv.Fail("/comment", http.StatusTooManyRequests, 1)
+	srv.Fail("/changelog", http.StatusTooManyRequests, 1)
+	jc := srv.Client(t, jira.WithMaxConcurrency(1))
+
+	collect(t, jc.GetIssuesByKeys(context.Background(), "TASK-1"))
+
+	// Comments and changelog are fetched concurrently: the first rate limited one lets the other one through
+	// before being retried.
+	var fetches []string
+	for _, r := range srv.Requests() {
+		if strings.Contains(r, "/comment") || strings.Contains(r, "/changelog") {
+			fetches = append(fetches, strings.SplitN(r, "?", 2)[0])
+		}
+	}
+	if len(fetches) < 2 || fetches[0] == fetches[1] {
+		t.Errorf("Got requests %q, want the other fetch before retrying the first one", fetches)
+	}
+}
+
+func TestAddComment(t *testing.T) {
+	t.Parallel()
+
+	const body = "Hello **world**\n\n- first\n- second"
+
+	tests := map[string]struct {
+		flavour jira.Flavour
+		key     string
+
+		wantErr error
+	}{
+		"Cloud":       {flavour: jira.Cloud, key: "EPIC-1"},
+		"Data Center": {flavour: jira.DataCenter, key: "EPIC-1"},
+
+		"Error on restricted issue": {key: "EPIC-2", wantErr: jira.ErrNotFound},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			srv := jiratest.NewServer(t, []jiratest.Issue{
+				{Key: "EPIC-1", IssueType: "Epic", Created: day},
+				{Key: "EPIC-2", IssueType: "Epic", Created: day, Restricted: true},
+			})
+			jc := srv.Client(t, jira.WithFlavour(tc.flavour))
+
+			err := jc.AddComment(context.Background(), tc.key, body)
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("AddComment returned %v, want %v", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("AddComment returned an unexpected error: %v", err)
+			}
+
+			posted, _ := srv.Issue(tc.key)
+			if len(posted.Comments) != 1 || posted.Comments[0].Body != body || posted.Comments[0].Author != jiratest.CurrentUser {
+				t.Errorf("Posted comments = %+v, want %q from %s", posted.Comments, body, jiratest.CurrentUser)
+			}
+		})
+	}
+}
+
+func TestCustomFields(t *testing.T) {
+	t.Parallel()
+
+	srv := jiratest.NewServer(t, []jiratest.Issue{
+		{Key: "TASK-1", IssueType: "Task", Created: day, CustomFields: map[string]any{
+			"customfield_1": 5.0,
+			"customfield_2": []any{map[string]any{"name": "Sprint 1"}, map[string]any{"name": "Sprint 2"}},
+			"customfield_3": map[string]any{"value": "Platform"},
+			"customfield_4": map[string]any{"displayName": "Alice"},
+			"customfield_5": nil,
+			"customfield_6": "Not requested",
+		}},
+	})
+
+	tests := map[string]struct {
+		customFields map[string]string
+
+		want map[string]string
+	}{
+		"Extracts configured fields as text": {
+			customFields: map[string]string{
+				"story_points": "customfield_1",
+				"sprint":       "customfield_2",
+				"team":         "customfield_3",
+				"reviewer":     "customfield_4",
+			},
+			want: map[string]string{"story_points": "5", "sprint": "Sprint 1, Sprint 2", "team": "Platform", "reviewer": "Alice"},
+		},
+		"Omits empty and missing fields": {
+			customFields: map[string]string{"empty": "customfield_5", "missing": "customfield_404"},
+		},
+		"No configured fields": {},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			jc := srv.Client(t, jira.WithCustomFields(tc.customFields))
+
+			got := collect(t, jc.GetIssuesByKeys(context.Background(), "TASK-1"))
+
+			if len(got[0].CustomFields) != len(tc.want) {
+				t.Errorf("CustomFields = %v, want %v", got[0].CustomFields, tc.want)
+			}
+			for name, want := range tc.want {
+				if got[0].CustomFields[name] != want {
+					t.Errorf("CustomFields[%q] = %q, want %q", name, got[0].CustomFields[name], want)
+				}
+			}
+		})
+	}
+}
+
+func TestGetFields(t *testing.T) {
+	t.Parallel()
+
+	srv := jiratest.NewServer(t, nil, jiratest.WithCustomFields(map[string]string{"customfield_10016": "Story Points"}))
+	jc := srv.Client(t)
+
+	fields, err := jc.GetFields(context.Background())
+	if err != nil {
+		t.Fatalf("GetFields returned an unexpected error: %v", err)
+	}
+
+	if !slices.Contains(fields, jira.Field{ID: "customfield_10016", Name: "Story Points", Custom: true}) {
+		t.Errorf("GetFields = %+v, want the Story Points custom field", fields)
+	}
+	if !slices.Contains(fields, jira.Field{ID: "summary", Name: "Summary"}) {
+		t.Errorf("GetFields = %+v, want the Summary standard field", fields)
+	}
+}
+
+// collect returns all issues of the sequence, failing the test on any error.
+func collect(t *testing.T, seq func(func(jira.Issue, error) bool)) []jira.Issue {
+	t.Helper()
+
+	var issues []jira.Issue
+	for issue, err := range seq {
+		if err != nil {
+			t.Fatalf("Unexpected error while retrieving issues: %v", err)
+		}
+		issues = append(issues, issue)
+	}
+	return issues
+}
+
+// tree returns a compact representation of the issue keys hierarchy, like A-1(B-1,B-2(C-1)).
+func tree(issues []jira.Issue) string {
+	var keys []string
+	for _, i := range issues {
+		k := i.Key
+		if len(i.Children) > 0 {
+			k += "(" + tree(i.Children) + ")"
+		}
+		keys = append(keys, k)
+	}
+	return strings.Join(keys, ",")
+}
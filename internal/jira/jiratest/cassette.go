@@ -0,0 +1,448 @@
+package jiratest
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/canonical/jira-summarizer/internal/jira"
+)
+
+var record bool
+
+func init() {
+	flag.BoolVar(&record, "record", false, "record cassettes from the Jira instance configured in the environment")
+}
+
+// Environment variables configuring the Jira instance cassettes are recorded from, as for the command.
+const (
+	envURL      = "JIRA_SUMMARIZER_JIRA_URL"
+	envUsername = "JIRA_SUMMARIZER_JIRA_USERNAME"
+	envAPIToken = "JIRA_SUMMARIZER_JIRA_API_TOKEN"
+	envPAT      = "JIRA_SUMMARIZER_JIRA_PERSONAL_ACCESS_TOKEN"
+)
+
+// CassetteURL is the base URL of the Jira instance in replayed cassettes.
+const CassetteURL = "https://jira.example.com"
+
+// Interaction is a recorded request with its response.
+type Interaction struct {
+	Method string
+	// URI is the path and query of the request, independent of the Jira instance.
+	URI        string
+	StatusCode int
+	Header     http.Header
+	// Body is the response body when it is JSON, and Text otherwise.
+	Body json.RawMessage `json:",omitempty"`
+	Text string          `json:",omitempty"`
+}
+
+// cassette is a set of recorded interactions, as stored on disk.
+type cassette struct {
+	Interactions []Interaction
+}
+
+// CassetteClient returns a Jira client replaying the interactions recorded in the cassette at path.
+//
+// When tests run with -record, the client talks to the Jira instance configured with the same environment
+// variables as the command instead, like JIRA_SUMMARIZER_JIRA_URL, and the scrubbed interactions are saved to
+// path at the end of the test.
+func CassetteClient(t *testing.T, path string, args ...jira.Option) *jira.Client {
+	t.Helper()
+
+	if !record {
+		r, err := LoadReplayer(path)
+		if err != nil {
+			t.Fatalf("Setup: %v", err)
+		}
+		jc, err := jira.NewClient(CassetteURL, jira.BasicAuth{Username: Username, Token: Token}, append(args, jira.WithTransport(r))...)
+		if err != nil {
+			t.Fatalf("Setup: failed to create Jira client: %v", err)
+		}
+		return jc
+	}
+
+	baseURL := os.Getenv(envURL)
+	if baseURL == "" {
+		t.Fatalf("Setup: %s must be set to record cassettes", envURL)
+	}
+	var auth jira.Authenticator = jira.BasicAuth{Username: os.Getenv(envUsername), Token: os.Getenv(envAPIToken)}
+	if pat := os.Getenv(envPAT); pat != "" {
+		auth = jira.BearerAuth{Token: pat}
+	}
+
+	rec := NewRecorder(http.DefaultTransport)
+	t.Cleanup(func() {
+		if err := rec.Save(path); err != nil {
+			t.Errorf("Failed to save cassette: %v", err)
+		}
+	})
+
+	jc, err := jira.NewClient(baseURL, auth, append(args, jira.WithTransport(rec))...)
+	if err != nil {
+		t.Fatalf("Setup: failed to create Jira client: %v", err)
+	}
+	return jc
+}
+
+// Recorder is an http.RoundTripper recording the interactions with a real Jira instance.
+// Request headers, and thus credentials, are never recorded.
+type Recorder struct {
+	next http.RoundTripper
+
+	mu           sync.Mutex
+	interactions []Interaction
+	origins      []string
+}
+
+// NewRecorder returns a recorder sending requests through next.
+func NewRecorder(next http.RoundTripper) *Recorder {
+	return &Recorder{next: next}
+}
+
+// RoundTrip sends the request and records it with its response.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	i := Interaction{
+		Method:     req.Method,
+		URI:        req.URL.RequestURI(),
+		StatusCode: resp.StatusCode,
+		Header:     http.Header{},
+	}
+	// Other headers identify the instance or the session.
+	for _, h := range []string{"Content-Type", "Retry-After"} {
+		if v := resp.Header.Values(h); len(v) > 0 {
+			i.Header[h] = v
+		}
+	}
+	if json.Valid(body) {
+		i.Body = body
+	} else {
+		i.Text = string(body)
+	}
+
+	origin := req.URL.Scheme + "://" + req.URL.Host
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.interactions = append(r.interactions, i)
+	if !slices.Contains(r.origins, origin) {
+		r.origins = append(r.origins, origin)
+	}
+
+	return resp, nil
+}
+
+// Save writes the recorded interactions to path, with personal data scrubbed.
+func (r *Recorder) Save(path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := newScrubber(r.origins)
+	c := cassette{Interactions: make([]Interaction, 0, len(r.interactions))}
+	for _, i := range r.interactions {
+		i.URI = s.scrubString(i.URI)
+		i.Text = s.scrubString(i.Text)
+		if i.Body != nil {
+			body, err := s.scrubJSON(i.Body)
+			if err != nil {
+				return fmt.Errorf("failed to scrub response to %s %s: %v", i.Method, i.URI, err)
+			}
+			i.Body = body
+		}
+		c.Interactions = append(c.Interactions, i)
+	}
+
+	var data bytes.Buffer
+	enc := json.NewEncoder(&data)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(c); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data.Bytes(), 0600)
+}
+
+// Replayer is an http.RoundTripper serving responses recorded in a cassette.
+// Requests are matched by method, path and query, ignoring the requested fields and expansions so that requesting
+// more of them does not invalidate recordings. Repeated requests are served the recorded responses in order,
+// the last one being served again once they are exhausted.
+type Replayer struct {
+	mu           sync.Mutex
+	interactions map[string][]Interaction
+	served       map[string]int
+}
+
+// LoadReplayer loads the cassette at path to replay it.
+func LoadReplayer(path string) (*Replayer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load cassette, run with -record to create it: %v", err)
+	}
+
+	var c cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("invalid cassette %s: %v", path, err)
+	}
+
+	r := &Replayer{
+		interactions: make(map[string][]Interaction),
+		served:       make(map[string]int),
+	}
+	for _, i := range c.Interactions {
+		u, err := url.ParseRequestURI(i.URI)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cassette %s: %v", path, err)
+		}
+		k := interactionKey(i.Method, u)
+		r.interactions[k] = append(r.interactions[k], i)
+	}
+	return r, nil
+}
+
+// ignoredParams are the query parameters which only select what is returned for the same issues.
+var ignoredParams = []string{"fields", "expand"}
+
+// interactionKey returns the key matching requests to recorded interactions, from their method, path and query
+// without the ignored parameters.
+func interactionKey(method string, u *url.URL) string {
+	q := u.Query()
+	for _, p := range ignoredParams {
+		q.Del(p)
+	}
+	k := method + " " + u.EscapedPath()
+	if len(q) > 0 {
+		k += "?" + q.Encode()
+	}
+	return k
+}
+
+// RoundTrip returns the recorded response to the request.
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	k := interactionKey(req.Method, req.URL)
+
+	r.mu.Lock()
+	recorded := r.interactions[k]
+	n := r.served[k]
+	r.served[k]++
+	r.mu.Unlock()
+
+	if len(recorded) == 0 {
+		return nil, fmt.Errorf("no recorded interaction for %s", k)
+	}
+	i := recorded[min(n, len(recorded)-1)]
+
+	body := []byte(i.Text)
+	if i.Body != nil {
+		body = i.Body
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", i.StatusCode, http.StatusText(i.StatusCode)),
+		StatusCode:    i.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        i.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+var (
+	emailRE       = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	wikiMentionRE = regexp.MustCompile(`\[~(accountid:)?([^\]]+)\]`)
+)
+
+// userFields are the changelog fields whose values identify users.
+var userFields = []string{"assignee", "reporter", "creator"}
+
+// scrubber replaces personal data and references to the recorded Jira instance in responses.
+// Users are replaced with pseudonyms, consistent across the whole cassette.
+type scrubber struct {
+	origins    []string
+	pseudonyms map[string]int
+	users      int
+}
+
+// newScrubber returns a scrubber replacing the given instance origins with CassetteURL.
+func newScrubber(origins []string) *scrubber {
+	return &scrubber{
+		origins:    origins,
+		pseudonyms: make(map[string]int),
+	}
+}
+
+// pseudonym returns the number of the pseudonym of a user identified by any of ids, like its account ID,
+// login or display name. All ids are then associated with that pseudonym.
+func (s *scrubber) pseudonym(ids ...any) int {
+	var n int
+	for _, id := range ids {
+		if known, ok := s.pseudonyms[fmt.Sprint(id)]; ok && id != nil {
+			n = known
+			break
+		}
+	}
+	if n == 0 {
+		s.users++
+		n = s.users
+	}
+
+	for _, id := range ids {
+		if id, ok := id.(string); ok && id != "" {
+			s.pseudonyms[id] = n
+		}
+	}
+	return n
+}
+
+// scrubJSON scrubs a JSON document.
+func (s *scrubber) scrubJSON(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(s.scrub(v))
+}
+
+// scrub scrubs a decoded JSON value recursively.
+func (s *scrubber) scrub(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		switch {
+		case isUser(v):
+			return s.scrubUser(v)
+		case v["type"] == "mention":
+			if attrs, ok := v["attrs"].(map[string]any); ok {
+				n := s.pseudonym(attrs["id"], strings.TrimPrefix(fmt.Sprint(attrs["text"]), "@"))
+				attrs["id"] = fmt.Sprintf("account-%d", n)
+				attrs["text"] = fmt.Sprintf("@User %d", n)
+			}
+		case isUserChange(v):
+			for _, side := range []string{"from", "to"} {
+				if v[side] == nil && v[side+"String"] == nil {
+					continue
+				}
+				n := s.pseudonym(v[side], v[side+"String"])
+				v[side] = fmt.Sprintf("account-%d", n)
+				v[side+"String"] = fmt.Sprintf("User %d", n)
+			}
+		}
+		// Sorted keys keep pseudonyms stable between recordings.
+		for _, k := range slices.Sorted(maps.Keys(v)) {
+			if id, ok := v[k].(string); ok && strings.HasSuffix(strings.ToLower(k), "accountid") {
+				v[k] = fmt.Sprintf("account-%d", s.pseudonym(id))
+				continue
+			}
+			v[k] = s.scrub(v[k])
+		}
+		return v
+
+	case []any:
+		for k, e := range v {
+			v[k] = s.scrub(e)
+		}
+		return v
+
+	case string:
+		return s.scrubString(v)
+	}
+
+	return v
+}
+
+// isUser returns if the JSON object is a user.
+func isUser(v map[string]any) bool {
+	if _, ok := v["displayName"]; !ok {
+		return false
+	}
+	for _, k := range []string{"accountId", "emailAddress", "avatarUrls"} {
+		if _, ok := v[k]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// isUserChange returns if the JSON object is a changelog item of a field holding a user.
+func isUserChange(v map[string]any) bool {
+	field, ok := v["field"].(string)
+	if !ok {
+		return false
+	}
+	for _, f := range userFields {
+		if strings.EqualFold(field, f) {
+			return true
+		}
+	}
+	return false
+}
+
+// scrubUser returns a pseudonymous user, keeping the shape of the original one.
+func (s *scrubber) scrubUser(v map[string]any) map[string]any {
+	n := s.pseudonym(v["accountId"], v["key"], v["name"], v["displayName"])
+
+	user := make(map[string]any, len(v))
+	for k, e := range v {
+		switch k {
+		case "displayName":
+			user[k] = fmt.Sprintf("User %d", n)
+		case "accountId":
+			user[k] = fmt.Sprintf("account-%d", n)
+		case "key", "name":
+			user[k] = fmt.Sprintf("user%d", n)
+		case "emailAddress":
+			user[k] = fmt.Sprintf("user%d@example.com", n)
+		case "self":
+			user[k] = fmt.Sprintf("%s/rest/api/2/user?accountId=account-%d", CassetteURL, n)
+		case "avatarUrls":
+			// Avatars can be pictures of the user.
+		case "timeZone":
+			user[k] = "Etc/UTC"
+		default:
+			user[k] = e
+		}
+	}
+	return user
+}
+
+// scrubString replaces the instance URL, email addresses and wiki markup mentions in a string.
+func (s *scrubber) scrubString(v string) string {
+	for _, origin := range s.origins {
+		v = strings.ReplaceAll(v, origin, CassetteURL)
+	}
+	v = emailRE.ReplaceAllString(v, "user@example.com")
+	return wikiMentionRE.ReplaceAllStringFunc(v, func(m string) string {
+		sub := wikiMentionRE.FindStringSubmatch(m)
+		return fmt.Sprintf("[~user%d]", s.pseudonym(sub[2]))
+	})
+}
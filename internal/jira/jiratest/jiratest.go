@@ -0,0 +1,941 @@
+// Package jiratest provides an in-process fake Jira server for tests.
+//
+// The server serves the subset of the Data Center (v2) and Cloud (v3) REST APIs used by the jira package
+// from an in-memory model: JQL searches, issues, comments and changelogs, with pagination.
+// Like on Data Center, issues are linked to their epic through the Epic Link field on v2, instead of being children.
+// Failures, like rate limiting, can be injected on demand.
+package jiratest
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/canonical/jira-summarizer/internal/adf"
+	"github.com/canonical/jira-summarizer/internal/jira"
+)
+
+const (
+	// Username is the user name accepted with Token for basic authentication.
+	Username = "tester@example.com"
+	// Token is the API token accepted for basic authentication, and the personal access token for bearer one.
+	Token = "jiratest-token"
+	// CurrentUser is the display name of the authenticated user, matching currentUser() and authoring posted comments.
+	CurrentUser = "Test User"
+)
+
+// epicLinkField is the ID of the Epic Link custom field, only listed on v2.
+const epicLinkField = "customfield_10014"
+
+// timeFormat is the format of times in Jira responses.
+const timeFormat = "2006-01-02T15:04:05.000-0700"
+
+// Issue is an issue of the fake Jira instance.
+type Issue struct {
+	Key string
+	// ID defaults to a unique number.
+	ID string
+	// FormerKeys are the keys of the issue before being moved: it can still be requested with them.
+	FormerKeys []string
+	Summary    string
+	// Description is Markdown, served as an ADF document on the v3 API.
+	Description string
+	IssueType   string
+	// SubTask marks issues with a sub-task issue type.
+	SubTask     bool
+	Status      string
+	Assignee    string
+	Reporter    string
+	Priority    string
+	Labels      []string
+	Components  []string
+	FixVersions []string
+	// DueDate is only served as a day.
+	DueDate time.Time
+	// Resolution is empty for unresolved issues. ResolutionDate is only served with a resolution.
+	Resolution     string
+	ResolutionDate time.Time
+	// Links are served with the summary and status of the linked issues.
+	Links []Link
+	// CustomFields are values of custom fields by ID, served as is when requested.
+	CustomFields map[string]any
+	// Parent is the key of the parent issue, if any. It is served as the epic link on v2 for issues of epics.
+	Parent  string
+	Created time.Time
+	// Updated defaults to the time of the most recent creation, comment or change.
+	Updated   time.Time
+	Comments  []Comment
+	Worklogs  []Worklog
+	Changelog []ChangeSet
+	// Restricted issues are not visible to the user: they are omitted from searches and can't be retrieved.
+	Restricted bool
+}
+
+// Comment is a comment on an issue.
+type Comment struct {
+	Author  string
+	Created time.Time
+	// Body is Markdown, served as an ADF document on the v3 API.
+	Body string
+}
+
+// Link is a link to another issue of the server.
+type Link struct {
+	// Relation is a direction of a link type, like "blocks" or "is blocked by".
+	Relation string
+	Key      string
+}
+
+// linkTypes are the standard link types, by outward and inward relations.
+var linkTypes = []struct {
+	name, outward, inward string
+}{
+	{name: "Blocks", outward: "blocks", inward: "is blocked by"},
+	{name: "Cloners", outward: "clones", inward: "is cloned by"},
+	{name: "Duplicate", outward: "duplicates", inward: "is duplicated by"},
+	{name: "Relates", outward: "relates to", inward: "relates to"},
+}
+
+// Worklog is time logged on an issue.
+type Worklog struct {
+	Author    string
+	Started   time.Time
+	TimeSpent time.Duration
+	// Comment is Markdown, served as an ADF document on the v3 API.
+	Comment string
+}
+
+// ChangeSet is a group of changes made at once on an issue.
+type ChangeSet struct {
+	Author  string
+	Created time.Time
+	Items   []ChangeItem
+}
+
+// ChangeItem is the change of a single field.
+type ChangeItem struct {
+	Field string
+	From  string
+	To    string
+}
+
+// updated returns the last time the issue was updated.
+func (i Issue) updated() time.Time {
+	if !i.Updated.IsZero() {
+		return i.Updated
+	}
+
+	updated := i.Created
+	for _, c := range i.Comments {
+		updated = maxTime(updated, c.Created)
+	}
+	for _, w := range i.Worklogs {
+		updated = maxTime(updated, w.Started)
+	}
+	for _, c := range i.Changelog {
+		updated = maxTime(updated, c.Created)
+	}
+	return updated
+}
+
+// maxTime returns the most recent of two times.
+func maxTime(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
+
+// Server is a fake Jira server.
+type Server struct {
+	*httptest.Server
+
+	pageSize      int
+	embeddedLimit int
+	customFields  map[string]string
+
+	mu       sync.Mutex
+	issues   []*Issue
+	failures []*failure
+	requests []string
+}
+
+// failure is a failure injected on requests matching a path.
+type failure struct {
+	pattern string
+	status  int
+	count   int
+}
+
+type options struct {
+	pageSize      int
+	embeddedLimit int
+	customFields  map[string]string
+}
+
+// Option configures the fake server.
+type Option func(*options)
+
+// WithPageSize caps the number of elements returned per page on paginated endpoints. Default is 50.
+func WithPageSize(n int) Option {
+	return func(o *options) {
+		o.pageSize = n
+	}
+}
+
+// WithEmbeddedLimit caps the number of comments and change sets embedded in issues, so that clients
+// need to fetch the rest separately. Default is 20.
+func WithEmbeddedLimit(n int) Option {
+	return func(o *options) {
+		o.embeddedLimit = n
+	}
+}
+
+// WithCustomFields names the custom fields of the instance by ID, to list them with the standard fields.
+func WithCustomFields(names map[string]string) Option {
+	return func(o *options) {
+		o.customFields = names
+	}
+}
+
+// NewServer starts a fake Jira server serving the given issues. It is closed at the end of the test.
+func NewServer(tb testing.TB, issues []Issue, args ...Option) *Server {
+	tb.Helper()
+
+	opts := options{
+		pageSize:      50,
+		embeddedLimit: 20,
+	}
+	for _, f := range args {
+		f(&opts)
+	}
+
+	s := &Server{
+		pageSize:      opts.pageSize,
+		embeddedLimit: opts.embeddedLimit,
+		customFields:  opts.customFields,
+	}
+	for _, i := range issues {
+		s.AddIssue(i)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /rest/api/2/search", s.search)
+	mux.HandleFunc("GET /rest/api/3/search/jql", s.search)
+	mux.HandleFunc("GET /rest/api/{version}/issue/{key}", s.issue)
+	mux.HandleFunc("GET /rest/api/{version}/issue/{key}/comment", s.comments)
+	mux.HandleFunc("POST /rest/api/{version}/issue/{key}/comment", s.addComment)
+	mux.HandleFunc("GET /rest/api/{version}/issue/{key}/worklog", s.worklogs)
+	mux.HandleFunc("GET /rest/api/3/issue/{key}/changelog", s.changelog)
+	mux.HandleFunc("GET /rest/api/{version}/field", s.fields)
+
+	s.Server = httptest.NewServer(s.middleware(mux))
+	tb.Cleanup(s.Close)
+
+	return s
+}
+
+// Client returns a Jira client authenticated on the server.
+func (s *Server) Client(tb testing.TB, args ...jira.Option) *jira.Client {
+	tb.Helper()
+
+	jc, err := jira.NewClient(s.URL, jira.BasicAuth{Username: Username, Token: Token}, args...)
+	if err != nil {
+		tb.Fatalf("Setup: failed to create Jira client: %v", err)
+	}
+	return jc
+}
+
+// AddIssue adds an issue to the server, or replaces the issue with the same key.
+func (s *Server) AddIssue(i Issue) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i.Key = strings.ToUpper(i.Key)
+	if i.ID == "" {
+		i.ID = strconv.Itoa(10000 + len(s.issues))
+	}
+
+	for idx, existing := range s.issues {
+		if existing.Key == i.Key {
+			s.issues[idx] = &i
+			return
+		}
+	}
+	s.issues = append(s.issues, &i)
+}
+
+// Issue returns the issue with the given key, with the comments posted on it.
+func (s *Server) Issue(key string) (Issue, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.find(key)
+	if i == nil {
+		return Issue{}, false
+	}
+	return *i, true
+}
+
+// Fail makes the next count requests whose path contains pattern fail with the given status.
+// Rate limiting and unavailability responses ask clients to retry immediately.
+func (s *Server) Fail(pattern string, status, count int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures = append(s.failures, &failure{pattern: pattern, status: status, count: count})
+}
+
+// Requests returns the method and URI of all requests received so far.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.requests)
+}
+
+// middleware records requests, injects failures and checks credentials before serving requests.
+func (s *Server) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, r.Method+" "+r.URL.RequestURI())
+		var status int
+		for _, f := range s.failures {
+			if f.count > 0 && strings.Contains(r.URL.Path, f.pattern) {
+				f.count--
+				status = f.status
+				break
+			}
+		}
+		s.mu.Unlock()
+
+		// Like Jira, set a session cookie on all responses.
+		w.Header().Set("Set-Cookie", "atlassian.xsrf.token=jiratest; Path=/")
+
+		if status != 0 {
+			if status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable {
+				w.Header().Set("Retry-After", "0")
+			}
+			writeError(w, status, "injected failure")
+			return
+		}
+
+		user, password, ok := r.BasicAuth()
+		if (!ok || user != Username || password != Token) && r.Header.Get("Authorization") != "Bearer "+Token {
+			writeError(w, http.StatusUnauthorized, "Client must be authenticated to access this resource.")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// find returns the issue matching the key, former key or ID, if any. It must be called with the lock held.
+func (s *Server) find(ref string) *Issue {
+	ref = strings.ToUpper(ref)
+	for _, i := range s.issues {
+		if i.Key == ref || i.ID == ref || slices.Contains(i.FormerKeys, ref) {
+			return i
+		}
+	}
+	return nil
+}
+
+// search serves JQL searches, with offset pagination on v2 and token pagination on v3.
+func (s *Server) search(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	v3 := strings.HasPrefix(r.URL.Path, "/rest/api/3/")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	matches, err := s.query(q.Get("jql"), v3)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var start int
+	if v3 {
+		if token := q.Get("nextPageToken"); token != "" {
+			if start, err = strconv.Atoi(token); err != nil {
+				writeError(w, http.StatusBadRequest, "Invalid nextPageToken.")
+				return
+			}
+		}
+	} else {
+		start, _ = strconv.Atoi(q.Get("startAt"))
+	}
+	maxResults := s.maxResults(q.Get("maxResults"))
+	start = min(start, len(matches))
+	end := min(start+maxResults, len(matches))
+
+	issues := make([]map[string]any, 0, end-start)
+	for _, i := range matches[start:end] {
+		issues = append(issues, s.jsonIssue(i, v3, q.Get("expand") == "changelog", strings.Split(q.Get("fields"), ",")))
+	}
+
+	if v3 {
+		page := map[string]any{
+			"issues": issues,
+			"isLast": end >= len(matches),
+		}
+		if end < len(matches) {
+			page["nextPageToken"] = strconv.Itoa(end)
+		}
+		writeJSON(w, http.StatusOK, page)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"startAt":    start,
+		"maxResults": maxResults,
+		"total":      len(matches),
+		"issues":     issues,
+	})
+}
+
+// issue serves a single issue, with its changelog if expanded.
+func (s *Server) issue(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.visibleIssue(w, r)
+	if i == nil {
+		return
+	}
+
+	v3 := r.PathValue("version") == "3"
+	writeJSON(w, http.StatusOK, s.jsonIssue(i, v3, r.URL.Query().Get("expand") == "changelog", strings.Split(r.URL.Query().Get("fields"), ",")))
+}
+
+// comments serves a page of the comments of an issue, in ascending order unless ordered by -created.
+func (s *Server) comments(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.visibleIssue(w, r)
+	if i == nil {
+		return
+	}
+
+	q := r.URL.Query()
+	comments := slices.SortedStableFunc(slices.Values(i.Comments), func(a, b Comment) int {
+		return a.Created.Compare(b.Created)
+	})
+	if q.Get("orderBy") == "-created" {
+		slices.Reverse(comments)
+	}
+
+	start, _ := strconv.Atoi(q.Get("startAt"))
+	maxResults := s.maxResults(q.Get("maxResults"))
+	start = min(start, len(comments))
+	end := min(start+maxResults, len(comments))
+
+	v3 := r.PathValue("version") == "3"
+	writeJSON(w, http.StatusOK, map[string]any{
+		"startAt":    start,
+		"maxResults": maxResults,
+		"total":      len(comments),
+		"comments":   jsonComments(comments[start:end], v3),
+	})
+}
+
+// worklogs serves a page of the worklogs of an issue, in creation order, optionally only those started after a time.
+func (s *Server) worklogs(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.visibleIssue(w, r)
+	if i == nil {
+		return
+	}
+
+	q := r.URL.Query()
+	worklogs := i.Worklogs
+	if after := q.Get("startedAfter"); after != "" {
+		ms, err := strconv.ParseInt(after, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid startedAfter.")
+			return
+		}
+		worklogs = slices.DeleteFunc(slices.Clone(worklogs), func(wl Worklog) bool {
+			return wl.Started.Before(time.UnixMilli(ms))
+		})
+	}
+
+	start, _ := strconv.Atoi(q.Get("startAt"))
+	maxResults := s.maxResults(q.Get("maxResults"))
+	start = min(start, len(worklogs))
+	end := min(start+maxResults, len(worklogs))
+
+	v3 := r.PathValue("version") == "3"
+	writeJSON(w, http.StatusOK, map[string]any{
+		"startAt":    start,
+		"maxResults": maxResults,
+		"total":      len(worklogs),
+		"worklogs":   jsonWorklogs(worklogs[start:end], v3),
+	})
+}
+
+// addComment adds a comment authored by the current user to an issue.
+func (s *Server) addComment(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		Body json.RawMessage
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request payload: %v", err))
+		return
+	}
+
+	v3 := r.PathValue("version") == "3"
+	// The v2 API only accepts plain strings and v3 only ADF documents.
+	if v3 != (len(payload.Body) > 0 && payload.Body[0] == '{') {
+		writeError(w, http.StatusBadRequest, "Comment body is not in the expected format.")
+		return
+	}
+	body, err := adf.TextOrMarkdown(payload.Body)
+	if err != nil || strings.TrimSpace(body) == "" {
+		writeError(w, http.StatusBadRequest, "Comment body can not be empty!")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.visibleIssue(w, r)
+	if i == nil {
+		return
+	}
+
+	c := Comment{
+		Author:  CurrentUser,
+		Created: time.Now(),
+		Body:    body,
+	}
+	i.Comments = append(i.Comments, c)
+
+	writeJSON(w, http.StatusCreated, jsonComments([]Comment{c}, v3)[0])
+}
+
+// changelog serves a page of the changelog of an issue, in ascending order.
+func (s *Server) changelog(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.visibleIssue(w, r)
+	if i == nil {
+		return
+	}
+
+	q := r.URL.Query()
+	start, _ := strconv.Atoi(q.Get("startAt"))
+	maxResults := s.maxResults(q.Get("maxResults"))
+	start = min(start, len(i.Changelog))
+	end := min(start+maxResults, len(i.Changelog))
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"startAt":    start,
+		"maxResults": maxResults,
+		"total":      len(i.Changelog),
+		"isLast":     end >= len(i.Changelog),
+		"values":     jsonChangeSets(i.Changelog[start:end]),
+	})
+}
+
+// standardFields are the standard fields listed by the fields endpoint, by ID.
+var standardFields = map[string]string{
+	"summary":     "Summary",
+	"description": "Description",
+	"status":      "Status",
+	"assignee":    "Assignee",
+	"duedate":     "Due date",
+}
+
+// fields lists the standard fields and the custom fields of the server, by ID, with the Epic Link one on v2.
+func (s *Server) fields(w http.ResponseWriter, r *http.Request) {
+	var fields []map[string]any
+	for _, id := range slices.Sorted(maps.Keys(standardFields)) {
+		fields = append(fields, map[string]any{"id": id, "key": id, "name": standardFields[id], "custom": false})
+	}
+	if r.PathValue("version") == "2" {
+		fields = append(fields, map[string]any{"id": epicLinkField, "key": epicLinkField, "name": "Epic Link", "custom": true})
+	}
+	for _, id := range slices.Sorted(maps.Keys(s.customFields)) {
+		fields = append(fields, map[string]any{"id": id, "key": id, "name": s.customFields[id], "custom": true})
+	}
+	writeJSON(w, http.StatusOK, fields)
+}
+
+// visibleIssue returns the issue of the request path, or writes a not found error if the user can't see it.
+// It must be called with the lock held.
+func (s *Server) visibleIssue(w http.ResponseWriter, r *http.Request) *Issue {
+	i := s.find(r.PathValue("key"))
+	if i == nil || i.Restricted {
+		writeError(w, http.StatusNotFound, "Issue does not exist or you do not have permission to see it.")
+		return nil
+	}
+	return i
+}
+
+// maxResults returns the requested page size, capped by the server one.
+func (s *Server) maxResults(requested string) int {
+	n, err := strconv.Atoi(requested)
+	if err != nil || n <= 0 {
+		return s.pageSize
+	}
+	return min(n, s.pageSize)
+}
+
+// jsonIssue returns the JSON representation of an issue, with truncated comments and changelog, as embedded
+// by Jira. Data Center embeds the whole changelog. Only the requested fields are returned, and all standard ones
+// when none is requested.
+func (s *Server) jsonIssue(i *Issue, v3, withChangelog bool, requested []string) map[string]any {
+	comments := slices.SortedStableFunc(slices.Values(i.Comments), func(a, b Comment) int {
+		return a.Created.Compare(b.Created)
+	})
+
+	fields := map[string]any{
+		"summary":     i.Summary,
+		"description": richText(i.Description, v3),
+		"created":     i.Created.Format(timeFormat),
+		"updated":     i.updated().Format(timeFormat),
+		"issuetype":   map[string]any{"name": i.IssueType, "subtask": i.SubTask},
+		"status":      jsonStatus(i.Status),
+		"comment": map[string]any{
+			"startAt":    0,
+			"maxResults": s.embeddedLimit,
+			"total":      len(comments),
+			"comments":   jsonComments(comments[:min(len(comments), s.embeddedLimit)], v3),
+		},
+		"worklog": map[string]any{
+			"startAt":    0,
+			"maxResults": s.embeddedLimit,
+			"total":      len(i.Worklogs),
+			"worklogs":   jsonWorklogs(i.Worklogs[:min(len(i.Worklogs), s.embeddedLimit)], v3),
+		},
+	}
+	if i.Assignee != "" {
+		fields["assignee"] = map[string]any{"displayName": i.Assignee}
+	}
+	if i.Reporter != "" {
+		fields["reporter"] = map[string]any{"displayName": i.Reporter}
+	}
+	if i.Priority != "" {
+		fields["priority"] = map[string]any{"name": i.Priority}
+	}
+	fields["labels"] = append([]string{}, i.Labels...)
+	fields["components"] = jsonNamed(i.Components)
+	fields["fixVersions"] = jsonNamed(i.FixVersions)
+	if !i.DueDate.IsZero() {
+		fields["duedate"] = i.DueDate.Format(time.DateOnly)
+	}
+	if i.Resolution != "" {
+		fields["resolution"] = map[string]any{"name": i.Resolution}
+		fields["resolutiondate"] = i.ResolutionDate.Format(timeFormat)
+	}
+	switch {
+	case s.epicLinked(i, v3):
+		fields[epicLinkField] = i.Parent
+	case i.Parent != "":
+		fields["parent"] = map[string]any{"key": i.Parent}
+	}
+	fields["issuelinks"] = s.jsonLinks(i.Links)
+	for _, id := range requested {
+		if v, ok := i.CustomFields[id]; ok {
+			fields[id] = v
+		}
+	}
+	if slices.ContainsFunc(requested, func(id string) bool { return id != "" }) {
+		maps.DeleteFunc(fields, func(id string, _ any) bool { return !slices.Contains(requested, id) })
+	}
+
+	j := map[string]any{
+		"id":     i.ID,
+		"key":    i.Key,
+		"fields": fields,
+	}
+
+	if withChangelog {
+		histories := i.Changelog
+		if v3 {
+			histories = histories[:min(len(histories), s.embeddedLimit)]
+		}
+		j["changelog"] = map[string]any{
+			"startAt":    0,
+			"maxResults": len(histories),
+			"total":      len(i.Changelog),
+			"histories":  jsonChangeSets(histories),
+		}
+	}
+
+	return j
+}
+
+// epicLinked returns if the issue is linked to its parent epic through the Epic Link field, which is the case of
+// standard issues on v2. It must be called with the lock held.
+func (s *Server) epicLinked(i *Issue, v3 bool) bool {
+	if v3 || i.SubTask || i.Parent == "" {
+		return false
+	}
+	parent := s.find(i.Parent)
+	return parent != nil && parent.IssueType == "Epic"
+}
+
+// jsonLinks returns the JSON representation of links, embedding the linked issues.
+// It must be called with the lock held.
+func (s *Server) jsonLinks(links []Link) []map[string]any {
+	r := make([]map[string]any, 0, len(links))
+	for _, l := range links {
+		// Other relations are served as symmetric link types.
+		t := struct{ name, outward, inward string }{name: l.Relation, outward: l.Relation, inward: l.Relation}
+		if idx := slices.IndexFunc(linkTypes, func(t struct{ name, outward, inward string }) bool {
+			return t.outward == l.Relation || t.inward == l.Relation
+		}); idx >= 0 {
+			t = linkTypes[idx]
+		}
+
+		linked := map[string]any{"key": l.Key}
+		if i := s.find(l.Key); i != nil {
+			linked["fields"] = map[string]any{
+				"summary": i.Summary,
+				"status":  jsonStatus(i.Status),
+			}
+		}
+
+		direction := "outwardIssue"
+		if l.Relation == t.inward {
+			direction = "inwardIssue"
+		}
+		r = append(r, map[string]any{
+			"type":    map[string]any{"name": t.name, "inward": t.inward, "outward": t.outward},
+			direction: linked,
+		})
+	}
+	return r
+}
+
+// jsonStatus returns the JSON representation of a status, in the done category only when named Done.
+func jsonStatus(name string) map[string]any {
+	category := "indeterminate"
+	if name == "Done" {
+		category = "done"
+	}
+	return map[string]any{"name": name, "statusCategory": map[string]any{"key": category}}
+}
+
+// jsonWorklogs returns the JSON representation of worklogs.
+func jsonWorklogs(worklogs []Worklog, v3 bool) []map[string]any {
+	r := make([]map[string]any, 0, len(worklogs))
+	for _, w := range worklogs {
+		j := map[string]any{
+			"author":           map[string]any{"displayName": w.Author},
+			"started":          w.Started.Format(timeFormat),
+			"timeSpentSeconds": int(w.TimeSpent.Seconds()),
+		}
+		if w.Comment != "" {
+			j["comment"] = richText(w.Comment, v3)
+		}
+		r = append(r, j)
+	}
+	return r
+}
+
+// jsonNamed returns the JSON representation of named Jira entities, like components or versions.
+func jsonNamed(names []string) []map[string]any {
+	r := make([]map[string]any, 0, len(names))
+	for _, name := range names {
+		r = append(r, map[string]any{"name": name})
+	}
+	return r
+}
+
+// jsonComments returns the JSON representation of comments.
+func jsonComments(comments []Comment, v3 bool) []map[string]any {
+	r := make([]map[string]any, 0, len(comments))
+	for _, c := range comments {
+		r = append(r, map[string]any{
+			"author":  map[string]any{"displayName": c.Author},
+			"body":    richText(c.Body, v3),
+			"created": c.Created.Format(timeFormat),
+			"updated": c.Created.Format(timeFormat),
+		})
+	}
+	return r
+}
+
+// jsonChangeSets returns the JSON representation of change sets.
+func jsonChangeSets(changeSets []ChangeSet) []map[string]any {
+	r := make([]map[string]any, 0, len(changeSets))
+	for _, c := range changeSets {
+		items := make([]map[string]any, 0, len(c.Items))
+		for _, item := range c.Items {
+			items = append(items, map[string]any{
+				"field":      item.Field,
+				"fieldtype":  "jira",
+				"fromString": item.From,
+				"toString":   item.To,
+			})
+		}
+		r = append(r, map[string]any{
+			"author":  map[string]any{"displayName": c.Author},
+			"created": c.Created.Format(timeFormat),
+			"items":   items,
+		})
+	}
+	return r
+}
+
+// richText returns the Markdown text as a string on v2 and as an ADF document on v3.
+func richText(md string, v3 bool) any {
+	switch {
+	case md == "":
+		return nil
+	case v3:
+		return adf.FromMarkdown(md)
+	default:
+		return md
+	}
+}
+
+// writeJSON writes v as the JSON response.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes a Jira error response.
+func writeError(w http.ResponseWriter, status int, messages ...string) {
+	writeJSON(w, status, map[string]any{
+		"errorMessages": messages,
+		"errors":        map[string]string{},
+	})
+}
+
+var (
+	jqlAndRE        = regexp.MustCompile(`(?i)\s+AND\s+`)
+	jqlOrRE         = regexp.MustCompile(`(?i)\s+OR\s+`)
+	jqlCurrentUser  = regexp.MustCompile(`(?i)^assignee\s*=\s*currentUser\(\)$`)
+	jqlCompareRE    = regexp.MustCompile(`(?i)^(issuetype|status)\s*(=|!=)\s*"?([^"]*)"?$`)
+	jqlInRE         = regexp.MustCompile(`(?i)^(key|parent|"Epic Link")\s+in\s*\(([^)]*)\)$`)
+	jqlUpdatedRE    = regexp.MustCompile(`(?i)^updated\s*(>=|<)\s*"([^"]+)"$`)
+	jqlNotSubTaskRE = regexp.MustCompile(`(?i)^issuetype\s+not\s+in\s+subTaskIssueTypes\(\)$`)
+)
+
+// query returns the visible issues matching the JQL query, on the v3 API or the v2 one.
+// Only conjunctions of the clauses used by the jira package are supported, or of parenthesized disjunctions of them.
+// It must be called with the lock held.
+func (s *Server) query(jql string, v3 bool) ([]*Issue, error) {
+	var filters []func(*Issue) bool
+	for _, clause := range jqlAndRE.Split(strings.TrimSpace(jql), -1) {
+		f, err := s.parseDisjunction(strings.TrimSpace(clause), v3)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, f)
+	}
+
+	var matches []*Issue
+	for _, i := range s.issues {
+		if i.Restricted {
+			continue
+		}
+		if !slices.ContainsFunc(filters, func(f func(*Issue) bool) bool { return !f(i) }) {
+			matches = append(matches, i)
+		}
+	}
+	return matches, nil
+}
+
+// parseDisjunction returns the filter corresponding to a single JQL clause, or to parenthesized clauses joined by OR.
+func (s *Server) parseDisjunction(clause string, v3 bool) (func(*Issue) bool, error) {
+	inner, ok := strings.CutPrefix(clause, "(")
+	if !ok || !strings.HasSuffix(inner, ")") || !jqlOrRE.MatchString(inner) {
+		return s.parseClause(clause, v3)
+	}
+
+	var filters []func(*Issue) bool
+	for _, c := range jqlOrRE.Split(strings.TrimSuffix(inner, ")"), -1) {
+		f, err := s.parseClause(strings.TrimSpace(c), v3)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, f)
+	}
+	return func(i *Issue) bool {
+		return slices.ContainsFunc(filters, func(f func(*Issue) bool) bool { return f(i) })
+	}, nil
+}
+
+// parseClause returns the filter corresponding to a single JQL clause.
+func (s *Server) parseClause(clause string, v3 bool) (func(*Issue) bool, error) {
+	switch {
+	case jqlCurrentUser.MatchString(clause):
+		return func(i *Issue) bool { return i.Assignee == CurrentUser }, nil
+
+	case jqlNotSubTaskRE.MatchString(clause):
+		return func(i *Issue) bool { return !i.SubTask }, nil
+
+	case jqlCompareRE.MatchString(clause):
+		m := jqlCompareRE.FindStringSubmatch(clause)
+		field, equal, value := strings.ToLower(m[1]), m[2] == "=", m[3]
+		return func(i *Issue) bool {
+			v := i.Status
+			if field == "issuetype" {
+				v = i.IssueType
+			}
+			return strings.EqualFold(v, value) == equal
+		}, nil
+
+	case jqlInRE.MatchString(clause):
+		m := jqlInRE.FindStringSubmatch(clause)
+		var values []string
+		for v := range strings.SplitSeq(m[2], ",") {
+			values = append(values, strings.ToUpper(strings.Trim(strings.TrimSpace(v), `"`)))
+		}
+
+		// Issues of epics are only found through the epic link on v2, and not as children.
+		switch strings.ToLower(m[1]) {
+		case "parent":
+			return func(i *Issue) bool {
+				return slices.Contains(values, strings.ToUpper(i.Parent)) && !s.epicLinked(i, v3)
+			}, nil
+		case `"epic link"`:
+			return func(i *Issue) bool {
+				return slices.Contains(values, strings.ToUpper(i.Parent)) && s.epicLinked(i, v3)
+			}, nil
+		}
+
+		// Like Jira, reject the whole query if any key does not exist, even if other keys do.
+		var matching []*Issue
+		for _, v := range values {
+			i := s.find(v)
+			if i == nil {
+				return nil, fmt.Errorf("an issue with key '%s' does not exist for field 'key'", v)
+			}
+			matching = append(matching, i)
+		}
+		return func(i *Issue) bool { return slices.Contains(matching, i) }, nil
+
+	case jqlUpdatedRE.MatchString(clause):
+		m := jqlUpdatedRE.FindStringSubmatch(clause)
+		t, err := time.ParseInLocation("2006/01/02 15:04", m[2], time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("date value '%s' for field 'updated' is invalid", m[2])
+		}
+		before := m[1] == "<"
+		return func(i *Issue) bool { return i.updated().Before(t) == before }, nil
+	}
+
+	return nil, fmt.Errorf("error in the JQL query: unsupported clause %q", clause)
+}
@@ -0,0 +1,164 @@
+package jira
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// maxRetries is the number of times a request is retried before giving up.
+	maxRetries = 5
+	// retryBaseDelay is the initial backoff delay, doubled on each attempt.
+	retryBaseDelay = 500 * time.Millisecond
+	// retryMaxDelay caps any delay between two attempts, including the ones requested by the server.
+	retryMaxDelay = time.Minute
+)
+
+// retryTransport retries requests rejected by Jira rate limiting or when the service is temporarily unavailable.
+type retryTransport struct {
+	next http.RoundTripper
+	// slots bounds the number of in-flight requests across the whole client.
+	// A slot is only held for a single attempt, until its response body is closed, and never while waiting to
+	// retry or for other issues, so that neither rate limited requests nor parents waiting on their children can
+	// starve other requests.
+	slots chan struct{}
+}
+
+// RoundTrip executes the request, retrying it when Jira asks us to.
+// Idempotent requests are retried on 429 and 503 with a jittered exponential backoff, unless the server
+// sets a Retry-After header.
+// Other requests are only retried on 429, as the server rejected them without processing.
+func (t retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		r := req
+		if attempt > 0 && req.Body != nil {
+			if req.GetBody == nil {
+				return nil, fmt.Errorf("can't retry %s %s: request body can't be replayed", req.Method, req.URL)
+			}
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r = req.Clone(req.Context())
+			r.Body = body
+		}
+
+		resp, err := t.roundTripInSlot(r)
+		if attempt >= maxRetries || !shouldRetry(req.Method, resp, err) {
+			if attempt > 0 {
+				slog.Debug(fmt.Sprintf("%s %s: done after %d retries", req.Method, req.URL.Path, attempt))
+			}
+			return resp, err
+		}
+
+		delay := retryDelay(resp, attempt)
+		if resp != nil {
+			slog.Debug(fmt.Sprintf("%s %s: got %s, retrying in %s", req.Method, req.URL.Path, resp.Status, delay))
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		} else {
+			slog.Debug(fmt.Sprintf("%s %s: %v, retrying in %s", req.Method, req.URL.Path, err, delay))
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// roundTripInSlot executes a single attempt of the request once a slot is available.
+// The slot is released when the response body is closed, or right away on error.
+func (t retryTransport) roundTripInSlot(req *http.Request) (*http.Response, error) {
+	select {
+	case t.slots <- struct{}{}:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+	release := sync.OnceFunc(func() { <-t.slots })
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	resp.Body = slotBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// slotBody is a response body releasing its request slot once closed.
+type slotBody struct {
+	io.ReadCloser
+	release func()
+}
+
+// Close closes the body and releases the request slot.
+func (b slotBody) Close() error {
+	defer b.release()
+	return b.ReadCloser.Close()
+}
+
+// isIdempotent returns if the method can safely be sent multiple times.
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// shouldRetry returns if the request should be sent again given the last response or error.
+func shouldRetry(method string, resp *http.Response, err error) bool {
+	if err != nil {
+		// Network errors are only safe to retry if we know the request has no side effect.
+		return isIdempotent(method)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusServiceUnavailable:
+		return isIdempotent(method)
+	}
+	return false
+}
+
+// retryDelay returns how long to wait before the next attempt.
+// It honours the Retry-After header and otherwise uses a jittered exponential backoff.
+func retryDelay(resp *http.Response, attempt int) time.Duration {
+	if resp != nil {
+		if d, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			return min(d, retryMaxDelay)
+		}
+	}
+
+	backoff := min(retryBaseDelay<<attempt, retryMaxDelay)
+	// Jitter spreads our concurrent requests instead of retrying them all at once.
+	return backoff/2 + rand.N(backoff/2+1)
+}
+
+// parseRetryAfter parses a Retry-After header, which is either a number of seconds or an HTTP date.
+func parseRetryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0), true
+	}
+
+	return 0, false
+}
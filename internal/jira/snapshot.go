@@ -0,0 +1,112 @@
+package jira
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ubuntu/decorate"
+)
+
+// snapshotVersion is the current version of the snapshot format.
+const snapshotVersion = 1
+
+// Snapshot is a set of fully fetched top issues, with their children, comments and status changes.
+// It allows summarising them later without network access.
+type Snapshot struct {
+	Version int
+	Created time.Time
+	// Since is the start of the window the issues were fetched for: older events may be missing.
+	Since  time.Time
+	Issues []Issue
+}
+
+// NewSnapshot creates a snapshot of the given issues, fetched for events since the given time.
+func NewSnapshot(since time.Time, issues []Issue) Snapshot {
+	return Snapshot{
+		Version: snapshotVersion,
+		Created: time.Now(),
+		Since:   since,
+		Issues:  issues,
+	}
+}
+
+// LoadSnapshot reads a snapshot from path.
+func LoadSnapshot(path string) (s Snapshot, err error) {
+	defer decorate.OnError(&err, "failed to load snapshot %s", path)
+
+	f, err := os.Open(path)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	defer f.Close()
+
+	if err := json.NewDecoder(f).Decode(&s); err != nil {
+		return Snapshot{}, err
+	}
+
+	if s.Version != snapshotVersion {
+		return Snapshot{}, fmt.Errorf("unsupported snapshot version %d", s.Version)
+	}
+
+	return s, nil
+}
+
+// Write serialises the snapshot to w.
+func (s Snapshot) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+// GetMyAssignedEpics returns all top issues of the snapshot.
+// Those are the assigned epics at the time of the snapshot if no key was given when taking it.
+func (s Snapshot) GetMyAssignedEpics(ctx context.Context) iter.Seq2[Issue, error] {
+	return func(yield func(Issue, error) bool) {
+		for _, issue := range s.Issues {
+			if more := yield(issue, nil); !more {
+				return
+			}
+		}
+	}
+}
+
+// AddComment always fails, as snapshots are read only.
+func (s Snapshot) AddComment(ctx context.Context, key, commentBody string) error {
+	return fmt.Errorf("can't add comment on issue %s: snapshots are read only", key)
+}
+
+// GetIssuesByKeys returns the top issues of the snapshot matching the keys, current or former, or IDs.
+// A *KeyError is yielded for each key missing from the snapshot.
+func (s Snapshot) GetIssuesByKeys(ctx context.Context, keys ...string) iter.Seq2[Issue, error] {
+	return func(yield func(Issue, error) bool) {
+		if len(keys) == 0 {
+			yield(Issue{}, fmt.Errorf("failed to retrieved issues: no issue keys provided"))
+			return
+		}
+
+		for _, key := range keys {
+			key = strings.ToUpper(strings.TrimSpace(key))
+			idx := slices.IndexFunc(s.Issues, func(i Issue) bool {
+				return i.Key == key || i.ID == key || slices.Contains(i.FormerKeys, key)
+			})
+
+			var more bool
+			if idx < 0 {
+				more = yield(Issue{}, &KeyError{Key: key, Err: errors.New("not in snapshot")})
+			} else {
+				more = yield(s.Issues[idx], nil)
+			}
+			if !more {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package jira
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/canonical/jira-summarizer/internal/adf"
+	"github.com/ubuntu/decorate"
+)
+
+// Worklog is time logged on an issue.
+type Worklog struct {
+	Who string
+	// Started is when the logged work started.
+	Started   time.Time
+	TimeSpent time.Duration
+	Comment   string
+}
+
+// TimeSpent returns the time logged on the issue and all its descendants.
+func (i Issue) TimeSpent() time.Duration {
+	var total time.Duration
+	for _, w := range i.Worklogs {
+		total += w.TimeSpent
+	}
+	for _, child := range i.Children {
+		total += child.TimeSpent()
+	}
+	return total
+}
+
+// TimeSpentPerPerson returns the time logged on the issue and all its descendants by each person.
+func (i Issue) TimeSpentPerPerson() map[string]time.Duration {
+	perPerson := make(map[string]time.Duration)
+	for _, w := range i.Worklogs {
+		perPerson[w.Who] += w.TimeSpent
+	}
+	for _, child := range i.Children {
+		for who, d := range child.TimeSpentPerPerson() {
+			perPerson[who] += d
+		}
+	}
+	return perPerson
+}
+
+// FormatTimeSpent returns the duration in hours and minutes, like Jira displays time spent, for instance "2h 30m".
+func FormatTimeSpent(d time.Duration) string {
+	d = d.Round(time.Minute)
+	hours, minutes := int(d/time.Hour), int((d%time.Hour)/time.Minute)
+	switch {
+	case hours == 0:
+		return fmt.Sprintf("%dm", minutes)
+	case minutes == 0:
+		return fmt.Sprintf("%dh", hours)
+	}
+	return fmt.Sprintf("%dh %dm", hours, minutes)
+}
+
+// jsonWorklog is a JSON representation of time logged on an issue.
+type jsonWorklog struct {
+	Author struct {
+		DisplayName string
+	}
+	Started          string
+	TimeSpentSeconds int
+	// Comment is a string on v2 API and an ADF document on v3. It is omitted when empty.
+	Comment json.RawMessage
+}
+
+// newWorklogFromJsonWorklog creates a new Worklog from its json representation.
+func newWorklogFromJsonWorklog(j jsonWorklog) (Worklog, error) {
+	started, err := parseJiraTime(j.Started)
+	if err != nil {
+		return Worklog{}, fmt.Errorf("failed to parse worklog start time %s: %v", j.Started, err)
+	}
+
+	var comment string
+	if len(j.Comment) > 0 {
+		comment, err = adf.TextOrMarkdown(j.Comment)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("failed to parse worklog comment: %v", err))
+			comment = string(j.Comment)
+		}
+	}
+
+	return Worklog{
+		Who:       j.Author.DisplayName,
+		Started:   started,
+		TimeSpent: time.Duration(j.TimeSpentSeconds) * time.Second,
+		Comment:   comment,
+	}, nil
+}
+
+// setWorklogs attaches the worklogs started after since to the issue, in ascending order of start.
+func (i *Issue) setWorklogs(worklogs []jsonWorklog, since time.Time) {
+	i.Worklogs = nil
+	for _, jWorklog := range worklogs {
+		worklog, err := newWorklogFromJsonWorklog(jWorklog)
+		if err != nil {
+			slog.Warn(fmt.Sprintf("issue %s: %v", i.Key, err))
+			continue
+		}
+		if worklog.Started.Before(since) {
+			continue
+		}
+		i.Worklogs = append(i.Worklogs, worklog)
+	}
+	// Worklogs are returned in creation order, which can differ from the order of the work.
+	slices.SortStableFunc(i.Worklogs, func(a, b Worklog) int { return a.Started.Compare(b.Started) })
+}
+
+// fetchWorklogs attaches all worklogs started in the client window to the issue in ascending order.
+// Jira can't sort worklogs, but Cloud filters them on their start time.
+func (i *Issue) fetchWorklogs(ctx context.Context, jc *Client) (err error) {
+	defer decorate.OnError(&err, "failed to get issue worklogs for %s", i.Key)
+
+	var worklogs []jsonWorklog
+	for startAt := 0; ; {
+		path := jc.apiPath(fmt.Sprintf("/issue/%s/worklog?startAt=%d&maxResults=%d", i.Key, startAt, pageSize))
+		if !jc.since.IsZero() {
+			path += fmt.Sprintf("&startedAfter=%d", jc.since.UnixMilli())
+		}
+
+		var result struct {
+			StartAt  int
+			Total    int
+			Worklogs []jsonWorklog
+		}
+		if err := jiraGet(ctx, jc, path, &result); err != nil {
+			return err
+		}
+		worklogs = append(worklogs, result.Worklogs...)
+
+		startAt = result.StartAt + len(result.Worklogs)
+		if len(result.Worklogs) == 0 || startAt >= result.Total {
+			break
+		}
+	}
+
+	i.setWorklogs(worklogs, jc.since)
+
+	return nil
+}
@@ -0,0 +1,80 @@
+// Package testutils provides helpers shared by tests.
+package testutils
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update bool
+
+func init() {
+	flag.BoolVar(&update, "update", false, "update golden files with the current output")
+}
+
+// GoldenPath returns the path of the golden file of the test, under testdata/golden, named after the test
+// and its parents.
+func GoldenPath(t *testing.T) string {
+	t.Helper()
+
+	return filepath.Join("testdata", "golden", t.Name())
+}
+
+// CheckOrUpdateGolden compares got with the golden file of the test.
+// When tests run with -update, the golden file is written with got instead.
+func CheckOrUpdateGolden(t *testing.T, got string) {
+	t.Helper()
+
+	path := GoldenPath(t)
+
+	if update {
+		if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+			t.Fatalf("Failed to create golden file directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0600); err != nil {
+			t.Fatalf("Failed to update golden file: %v", err)
+		}
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read golden file %s, run with -update to create it: %v", path, err)
+	}
+
+	if got != string(want) {
+		t.Errorf("Output differs from golden file %s (run with -update to refresh it)\n%s", path, diff(string(want), got))
+	}
+}
+
+// diff returns a line by line comparison of want and got, with differing lines prefixed with - and +.
+func diff(want, got string) string {
+	wantLines := strings.Split(want, "\n")
+	gotLines := strings.Split(got, "\n")
+
+	var sb strings.Builder
+	for i := range max(len(wantLines), len(gotLines)) {
+		var w, g string
+		var hasW, hasG bool
+		if i < len(wantLines) {
+			w, hasW = wantLines[i], true
+		}
+		if i < len(gotLines) {
+			g, hasG = gotLines[i], true
+		}
+
+		if hasW && hasG && w == g {
+			sb.WriteString("  " + w + "\n")
+			continue
+		}
+		if hasW {
+			sb.WriteString("- " + w + "\n")
+		}
+		if hasG {
+			sb.WriteString("+ " + g + "\n")
+		}
+	}
+	return sb.String()
+}
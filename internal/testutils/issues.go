@@ -0,0 +1,155 @@
+package testutils
+
+import (
+	"time"
+
+	"github.com/canonical/jira-summarizer/internal/jira"
+)
+
+// Day is the reference time of test issues: they are created the day before and commented on that day.
+var Day = time.Date(2025, time.June, 10, 10, 0, 0, 0, time.UTC)
+
+// NewIssue returns an issue with a description and a comment.
+func NewIssue(key, issueType string) jira.Issue {
+	return jira.Issue{
+		Key:         key,
+		URL:         "https://jira.example.com/browse/" + key,
+		Summary:     "Summary of " + key,
+		Description: "Description of " + key,
+		Created:     Day.AddDate(0, 0, -1),
+		IssueType:   issueType,
+		Comments:    []jira.Comment{{Content: "Comment on " + key, Who: "Alice", When: Day}},
+	}
+}
+
+// WithChildren returns the issue with the given children.
+func WithChildren(i jira.Issue, children ...jira.Issue) jira.Issue {
+	i.Children = children
+	return i
+}
+
+// IssueCases returns issues covering all rendered elements, by test case name, to compare with golden files.
+func IssueCases() map[string]jira.Issue {
+	return map[string]jira.Issue{
+		"Issue without children": NewIssue("TASK-1", "Task"),
+		"Virtual issue": {
+			Key:      "virtual",
+			Children: []jira.Issue{NewIssue("EPIC-1", "Epic"), NewIssue("EPIC-2", "Epic")},
+		},
+		"Embedder": WithChildren(NewIssue("EPIC-1", "Epic"), NewIssue("TASK-1", "Task"), NewIssue("TASK-2", "Task")),
+		"Deep nesting": WithChildren(NewIssue("OBJ-1", "Objective"),
+			WithChildren(NewIssue("EPIC-1", "Epic"),
+				WithChildren(NewIssue("TASK-1", "Task"), NewIssue("SUB-1", "Sub-task"))),
+			NewIssue("EPIC-2", "Epic")),
+		"Empty description": func() jira.Issue {
+			i := NewIssue("TASK-1", "Task")
+			i.Description = ""
+			return i
+		}(),
+		"Multi-line description and comments": func() jira.Issue {
+			i := NewIssue("TASK-1", "Task")
+			i.Description = "First line\n\n- item 1\n- item 2"
+			i.Comments = append(i.Comments, jira.Comment{
+				Content: "Multi-line comment:\n\n```\ncode\n```\nLast line",
+				Who:     "Bob",
+				When:    Day.Add(2 * time.Hour),
+			})
+			return i
+		}(),
+		"Moved issue with status change": func() jira.Issue {
+			i := NewIssue("NEW-1", "Task")
+			i.FormerKeys = []string{"OLD-1", "OLDER-1"}
+			i.Status.Name = "Done"
+			i.Status.Who = "Carol"
+			i.Status.When = Day.Add(3 * time.Hour)
+			return i
+		}(),
+		"Issue fields": func() jira.Issue {
+			i := NewIssue("TASK-1", "Task")
+			i.Assignee = "Alice"
+			i.Reporter = "Bob"
+			i.Priority = "P1"
+			i.Labels = []string{"security", "regression"}
+			i.Components = []string{"Login"}
+			i.FixVersions = []string{"1.0", "1.1"}
+			i.DueDate = Day.Truncate(24*time.Hour).AddDate(0, 0, -1)
+			i.Resolution = "Done"
+			i.ResolutionDate = Day.Add(time.Hour)
+			i.Changes = []jira.Change{
+				{Field: "assignee", From: "Carol", To: "Alice", Who: "Bob", When: Day},
+				{Field: "reporter", To: "Bob", Who: "Bob", When: Day},
+				{Field: "labels", From: "security", To: "security regression", Who: "Bob", When: Day},
+				{Field: "Component", To: "Login", Who: "Bob", When: Day},
+				{Field: "Fix Version", To: "1.1", Who: "Bob", When: Day},
+				{Field: "resolution", To: "Done", Who: "Alice", When: Day.Add(time.Hour)},
+			}
+			return i
+		}(),
+		"Unchanged issue fields are hidden": func() jira.Issue {
+			i := NewIssue("TASK-1", "Task")
+			i.Assignee = "Alice"
+			i.Reporter = "Bob"
+			i.Priority = "P1"
+			i.Labels = []string{"security"}
+			i.Components = []string{"Login"}
+			i.FixVersions = []string{"1.0"}
+			i.DueDate = Day.Truncate(24*time.Hour).AddDate(0, 0, -1)
+			i.Resolution = "Done"
+			i.ResolutionDate = Day.Add(time.Hour)
+			return i
+		}(),
+		"Open issue past its due date": func() jira.Issue {
+			i := NewIssue("TASK-1", "Task")
+			i.Assignee = "Alice"
+			i.Priority = "P1"
+			i.DueDate = Day.Truncate(24*time.Hour).AddDate(0, 0, -1)
+			return i
+		}(),
+		"Custom fields": func() jira.Issue {
+			i := NewIssue("TASK-1", "Task")
+			i.CustomFields = map[string]string{"story_points": "3", "sprint": "Sprint 1, Sprint 2"}
+			return i
+		}(),
+		"Field changes": func() jira.Issue {
+			i := NewIssue("TASK-1", "Task")
+			i.Changes = []jira.Change{
+				{Field: "assignee", To: "Alice", Who: "Bob", When: Day},
+				{Field: "priority", From: "Medium", To: "High", Who: "Bob", When: Day.Add(time.Hour)},
+				{Field: "duedate", From: "2025-06-20", Who: "Carol", When: Day.Add(2 * time.Hour)},
+				{Field: "description", From: "Old", To: "New\nmulti-line description", Who: "Alice", When: Day.Add(3 * time.Hour)},
+			}
+			return i
+		}(),
+		"Links": func() jira.Issue {
+			i := NewIssue("TASK-1", "Task")
+			i.Links = []jira.Link{
+				{Relation: "is blocked by", Key: "OPS-1", Summary: "Provision servers", Status: "In Progress"},
+				{Relation: "relates to", Key: "TASK-2", Summary: "Summary of TASK-2", Status: "Done", Done: true},
+			}
+			i.LinkChanges = []jira.LinkChange{
+				{Description: "This issue is blocked by OPS-1", Key: "OPS-1", Who: "Bob", When: Day},
+				{Description: "This issue duplicates TASK-3", Key: "TASK-3", Removed: true, Who: "Bob", When: Day.Add(time.Hour)},
+			}
+			return i
+		}(),
+		"Worklogs": func() jira.Issue {
+			i := NewIssue("TASK-1", "Task")
+			i.Worklogs = []jira.Worklog{
+				{Who: "Alice", Started: Day, TimeSpent: 2*time.Hour + 30*time.Minute, Comment: "Investigation\nand fix"},
+				{Who: "Bob", Started: Day.Add(3 * time.Hour), TimeSpent: 45 * time.Minute},
+			}
+			return i
+		}(),
+		"Status transitions": func() jira.Issue {
+			i := NewIssue("TASK-1", "Task")
+			i.Status.Name = "In Progress"
+			i.Status.Who = "Carol"
+			i.Status.When = Day.Add(3 * time.Hour)
+			i.Transitions = []jira.Transition{
+				{From: "In Progress", To: "Blocked", Who: "Bob", When: Day.Add(time.Hour)},
+				{From: "Blocked", To: "In Progress", Who: "Carol", When: Day.Add(3 * time.Hour)},
+			}
+			return i
+		}(),
+	}
+}
@@ -1,13 +1,20 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"iter"
 	"log"
 	"log/slog"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"slices"
 	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
 
 	_ "embed"
 
@@ -17,10 +24,15 @@ import (
 	"github.com/spf13/viper"
 )
 
+// envPrefix returns the prefix of the environment variables of the program.
+func envPrefix(name string) string {
+	return strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
 func newViperConfig(name string) (*viper.Viper, error) {
 	vip := viper.New()
-	vip.SetEnvPrefix(strings.ReplaceAll(name, "-", "_"))
-	vip.SetEnvKeyReplacer(strings.NewReplacer(".", "_")) // So Jira.Username → PULSE_SUMMARIZER_JIRA_USERNAME
+	vip.SetEnvPrefix(envPrefix(name))
+	vip.SetEnvKeyReplacer(strings.NewReplacer(".", "_")) // So Jira.Username → JIRA_SUMMARIZER_JIRA_USERNAME
 	vip.AutomaticEnv()
 	vip.SetConfigName(name)
 	vip.AddConfigPath(".")
@@ -42,6 +54,43 @@ var configExample string
 
 var validGroupOptions = []string{"top", "merge", "children"}
 
+// defaultJiraURL is the Jira instance used when none is configured.
+const defaultJiraURL = "https://warthogs.atlassian.net"
+
+// credentialKeys are the configuration keys of the Jira credentials.
+var credentialKeys = []string{"username", "api_token", "personal_access_token"}
+
+// applyProfile merges the settings of the named profile over the top level configuration.
+// A profile pointing to another Jira instance doesn't inherit the top level credentials, so that they are never sent to
+// another host.
+// Environment variables and flags still take precedence over the profile.
+func applyProfile(vip *viper.Viper, name string) error {
+	if name == "" {
+		return nil
+	}
+
+	profile := vip.Sub("profiles." + name)
+	if profile == nil {
+		return fmt.Errorf("unknown profile %q", name)
+	}
+
+	settings := profile.AllSettings()
+	if profile.IsSet("jira.url") {
+		jiraSettings, _ := settings["jira"].(map[string]any)
+		for _, key := range credentialKeys {
+			if _, ok := jiraSettings[key]; !ok {
+				jiraSettings[key] = ""
+			}
+		}
+	}
+
+	if err := vip.MergeConfigMap(settings); err != nil {
+		return fmt.Errorf("invalid profile %q: %v", name, err)
+	}
+
+	return nil
+}
+
 func main() {
 	// Remove date and time from log output to keep it clean.
 	log.SetFlags(log.Flags() &^ (log.Ldate | log.Ltime))
@@ -61,18 +110,38 @@ func main() {
 		SilenceErrors: true,
 		Args:          cobra.ArbitraryArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runRoot(vip, args)
+			return runRoot(cmd.Context(), vip, args)
 		},
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 			cmd.SilenceUsage = true
 
-			if vip.Get("jira.username") == "" || vip.Get("jira.api_token") == nil {
+			if vip.GetBool("verbose") {
+				slog.SetLogLoggerLevel(slog.LevelDebug)
+			}
+
+			if err := applyProfile(vip, vip.GetString("profile")); err != nil {
+				return err
+			}
+
+			if _, err := jira.ParseFlavour(vip.GetString("jira.flavour")); err != nil {
+				return err
+			}
+
+			// Replaying a snapshot does not need any connection.
+			if vip.GetString("from-snapshot") != "" && !vip.GetBool("no-post") {
+				slog.Info("summaries from a snapshot can’t be posted on Jira. Only doing a summary.")
+				vip.Set("no-post", true)
+			}
+
+			if vip.GetString("from-snapshot") == "" &&
+				vip.GetString("jira.personal_access_token") == "" && (vip.GetString("jira.username") == "" || vip.GetString("jira.api_token") == "") {
 				return fmt.Errorf(`missing configuration. Please set:
-  * PULSE_SUMMARIZER_JIRA_USERNAME (your email)")
-  * PULSE_SUMMARIZER_IRA_API_TOKEN (API token from your Atlassian account)")
-You can also store them permanently in a configuration file named %s.yaml with:
+  * %[1]s_JIRA_USERNAME (your email)
+  * %[1]s_JIRA_API_TOKEN (API token from your Atlassian account)
+or, on Jira Data Center, %[1]s_JIRA_PERSONAL_ACCESS_TOKEN.
+You can also store them permanently in a configuration file named %[2]s.yaml with:
 
-%v`, name, configExample)
+%[3]v`, envPrefix(name), name, configExample)
 			}
 
 			// Ensure group is one of the valid options.
@@ -80,6 +149,12 @@ You can also store them permanently in a configuration file named %s.yaml with:
 				return fmt.Errorf("invalid group value: %q. Valid options are: %s", vip.GetString("group"), strings.Join(validGroupOptions, ", "))
 			}
 
+			// Posting requires a connection.
+			if vip.GetBool("offline") && !vip.GetBool("no-post") {
+				slog.Info("summaries can’t be posted on Jira in offline mode. Only doing a summary.")
+				vip.Set("no-post", true)
+			}
+
 			// Fallback to summary only when using the merge strategy.
 			if vip.GetString("group") == "merge" {
 				if !vip.GetBool("no-post") {
@@ -92,24 +167,65 @@ You can also store them permanently in a configuration file named %s.yaml with:
 		},
 	}
 
-	rootCmd.Flags().String("jira-username", "", "jira username to use to connect to")
-	err = vip.BindPFlag("jira.username", rootCmd.Flags().Lookup("jira-username"))
+	rootCmd.PersistentFlags().String("jira-username", "", "jira username to use to connect to")
+	err = vip.BindPFlag("jira.username", rootCmd.PersistentFlags().Lookup("jira-username"))
 	if err != nil {
 		log.Fatalf("program error: unable to bind flag jira-username: %v", err)
 	}
 
+	rootCmd.PersistentFlags().String("jira-url", defaultJiraURL, "base URL of the jira instance to connect to")
+	err = vip.BindPFlag("jira.url", rootCmd.PersistentFlags().Lookup("jira-url"))
+	if err != nil {
+		log.Fatalf("program error: unable to bind flag jira-url: %v", err)
+	}
+
 	rootCmd.Flags().Bool("no-post", false, "do not offer posting the summary to the grouping jira tickets")
 	err = vip.BindPFlag("no-post", rootCmd.Flags().Lookup("no-post"))
 	if err != nil {
 		log.Fatalf("program error: unable to bind flag no-post: %v", err)
 	}
 
+	rootCmd.PersistentFlags().BoolP("verbose", "v", false, "print debug logs, like network retries")
+	if err = vip.BindPFlag("verbose", rootCmd.PersistentFlags().Lookup("verbose")); err != nil {
+		log.Fatalf("program error: unable to bind flag 'verbose': %v", err)
+	}
+
+	rootCmd.PersistentFlags().StringP("profile", "p", "", "named profile from the configuration file to use")
+	if err = vip.BindPFlag("profile", rootCmd.PersistentFlags().Lookup("profile")); err != nil {
+		log.Fatalf("program error: unable to bind flag 'profile': %v", err)
+	}
+
+	rootCmd.PersistentFlags().Bool("cache", false, "cache jira responses on disk to speed up successive runs")
+	if err = vip.BindPFlag("cache.enabled", rootCmd.PersistentFlags().Lookup("cache")); err != nil {
+		log.Fatalf("program error: unable to bind flag 'cache': %v", err)
+	}
+
+	rootCmd.PersistentFlags().Duration("cache-ttl", time.Hour, "how long cached jira responses are used before being fetched again")
+	if err = vip.BindPFlag("cache.ttl", rootCmd.PersistentFlags().Lookup("cache-ttl")); err != nil {
+		log.Fatalf("program error: unable to bind flag 'cache-ttl': %v", err)
+	}
+
+	rootCmd.PersistentFlags().Bool("refresh", false, "ignore cached jira responses and fetch them again, updating the cache")
+	if err = vip.BindPFlag("refresh", rootCmd.PersistentFlags().Lookup("refresh")); err != nil {
+		log.Fatalf("program error: unable to bind flag 'refresh': %v", err)
+	}
+
+	rootCmd.PersistentFlags().Bool("offline", false, "only use cached jira responses, whatever their age, without connecting to jira")
+	if err = vip.BindPFlag("offline", rootCmd.PersistentFlags().Lookup("offline")); err != nil {
+		log.Fatalf("program error: unable to bind flag 'offline': %v", err)
+	}
+
+	rootCmd.PersistentFlags().Bool("strict", false, "fail if any of the given jira tickets can't be retrieved, instead of skipping it")
+	if err = vip.BindPFlag("strict", rootCmd.PersistentFlags().Lookup("strict")); err != nil {
+		log.Fatalf("program error: unable to bind flag 'strict': %v", err)
+	}
+
 	var since sinceflag.SinceValue
 	if err := since.Set("2w"); err != nil {
 		log.Fatalf("program error: invalid default value for --since: %v", err)
 	}
-	rootCmd.Flags().VarP(&since, "since", "s", "Start time or relative duration (e.g. '2004-10-20', '6mo', '1w', '5d')")
-	if err = vip.BindPFlag("since", rootCmd.Flags().Lookup("since")); err != nil {
+	rootCmd.PersistentFlags().VarP(&since, "since", "s", "Start time or relative duration (e.g. '2004-10-20', '6mo', '1w', '5d')")
+	if err = vip.BindPFlag("since", rootCmd.PersistentFlags().Lookup("since")); err != nil {
 		log.Fatalf("program error: unable to bind flag 'since': %v", err)
 	}
 
@@ -124,46 +240,251 @@ You can also store them permanently in a configuration file named %s.yaml with:
 		log.Fatalf("program error: unable to bind flag 'group': %v", err)
 	}
 
-	if err := rootCmd.Execute(); err != nil {
-		slog.Error(err.Error())
+	rootCmd.Flags().String("from-snapshot", "", "summarize issues from a snapshot file instead of fetching them from jira")
+	if err = vip.BindPFlag("from-snapshot", rootCmd.Flags().Lookup("from-snapshot")); err != nil {
+		log.Fatalf("program error: unable to bind flag 'from-snapshot': %v", err)
+	}
+
+	rootCmd.PersistentFlags().Duration("timeout", 0, "abort if fetching issues takes longer than this duration (e.g. '5m'), editing summaries is not limited. 0 means no timeout")
+	if err = vip.BindPFlag("timeout", rootCmd.PersistentFlags().Lookup("timeout")); err != nil {
+		log.Fatalf("program error: unable to bind flag 'timeout': %v", err)
+	}
+
+	var snapshotOutput string
+	snapshotCmd := cobra.Command{
+		Use:   "snapshot [JIRA_TICKET…]",
+		Short: "Save fetched issues to a file",
+		Long:  "Fetch the Jira tickets with their children, comments and status changes, and save them to a file to summarize later with --from-snapshot. If no Jira ticket is provided, all active assigned epics are considered.",
+		Args:  cobra.ArbitraryArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSnapshot(cmd.Context(), vip, snapshotOutput, args)
+		},
+	}
+	snapshotCmd.Flags().StringVarP(&snapshotOutput, "output", "o", "", "file to write the snapshot to, or '-' for standard output")
+	if err := snapshotCmd.MarkFlagRequired("output"); err != nil {
+		log.Fatalf("program error: unable to mark flag 'output' as required: %v", err)
+	}
+	rootCmd.AddCommand(&snapshotCmd)
+
+	fieldsCmd := cobra.Command{
+		Use:   "fields",
+		Short: "List the fields of the Jira instance",
+		Long:  "List the ID and name of all issue fields of the Jira instance, to map custom fields, like story points or sprint, to friendly names in the fields section of the configuration.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runFields(cmd.Context(), vip)
+		},
+	}
+	rootCmd.AddCommand(&fieldsCmd)
+
+	// Cancel all in flight requests on first interruption. A second one kills the program.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
+		switch {
+		case errors.Is(err, context.Canceled):
+			slog.Error("interrupted")
+		case errors.Is(err, context.DeadlineExceeded):
+			slog.Error(fmt.Sprintf("timed out after %s", vip.GetDuration("timeout")))
+		default:
+			slog.Error(err.Error())
+		}
+		if hint := errorHint(err); hint != "" {
+			fmt.Fprintln(os.Stderr, hint)
+		}
 		os.Exit(1)
 	}
 }
 
-// run executes the main logic of the command.
-func runRoot(vip *viper.Viper, args []string) error {
-	jiraClient, err := jira.NewClient("https://warthogs.atlassian.net", vip.GetString("jira.username"), vip.GetString("jira.api_token"))
+// cacheOption returns the client option for the on-disk cache, or nil if the cache is disabled.
+// Refreshing or working offline implies using the cache.
+func cacheOption(vip *viper.Viper) (jira.Option, error) {
+	mode := jira.CacheDefault
+	switch {
+	case vip.GetBool("offline") && vip.GetBool("refresh"):
+		return nil, errors.New("--offline and --refresh are mutually exclusive")
+	case vip.GetBool("offline"):
+		mode = jira.CacheOffline
+	case vip.GetBool("refresh"):
+		mode = jira.CacheRefresh
+	case !vip.GetBool("cache.enabled"):
+		return nil, nil
+	}
+
+	dir, err := os.UserCacheDir()
 	if err != nil {
-		return fmt.Errorf("invalid jira Client: %v", err)
+		return nil, fmt.Errorf("can't find cache directory: %v", err)
+	}
+
+	return jira.WithCache(filepath.Join(dir, "jira-summarizer"), vip.GetDuration("cache.ttl"), mode), nil
+}
+
+// errorHint returns an actionable hint for the user depending on the error returned by Jira, if any.
+func errorHint(err error) string {
+	switch {
+	case errors.Is(err, jira.ErrUnauthorized):
+		return "Jira rejected your credentials: check your username and API token. Your API token may have expired."
+	case errors.Is(err, jira.ErrForbidden):
+		return "Your account has no permission for this request: check that you can access it from the Jira web interface."
+	case errors.Is(err, jira.ErrNotFound):
+		return "Jira could not find the requested resource: check the issue keys and the Jira URL."
+	case errors.Is(err, jira.ErrRateLimited):
+		return "Jira is rate limiting us: try again later or lower jira.max_concurrency."
+	case errors.Is(err, jira.ErrNotCached):
+		return "Some data was never cached: run once without --offline to fetch it."
 	}
+	return ""
+}
+
+// withTimeout returns a context cancelled after the configured timeout, if any.
+func withTimeout(ctx context.Context, vip *viper.Viper) (context.Context, context.CancelFunc) {
+	if timeout := vip.GetDuration("timeout"); timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// fetchWithTimeout iterates over the issues of fetch, aborting once waiting for them took longer than the configured
+// timeout, if any. The time spent by the caller between issues is not counted.
+func fetchWithTimeout(ctx context.Context, vip *viper.Viper, fetch func(context.Context) iter.Seq2[jira.Issue, error]) iter.Seq2[jira.Issue, error] {
+	timeout := vip.GetDuration("timeout")
+	if timeout <= 0 {
+		return fetch(ctx)
+	}
+
+	return func(yield func(jira.Issue, error) bool) {
+		ctx, cancel := context.WithCancelCause(ctx)
+		defer cancel(nil)
+
+		next, stop := iter.Pull2(fetch(ctx))
+		defer stop()
+
+		for {
+			start := time.Now()
+			timer := time.AfterFunc(timeout, func() { cancel(context.DeadlineExceeded) })
+			issue, err, ok := next()
+			timer.Stop()
+			timeout -= time.Since(start)
 
+			if !ok {
+				return
+			}
+			if err != nil && errors.Is(context.Cause(ctx), context.DeadlineExceeded) {
+				// Requests fail as cancelled, report the timeout instead.
+				err = context.DeadlineExceeded
+			}
+			if !yield(issue, err) {
+				return
+			}
+		}
+	}
+}
+
+// newJiraClient creates the jira client from the configuration, fetching events since sinceTime.
+func newJiraClient(vip *viper.Viper, sinceTime time.Time) (*jira.Client, error) {
+	flavour, err := jira.ParseFlavour(vip.GetString("jira.flavour"))
+	if err != nil {
+		return nil, err
+	}
+
+	var auth jira.Authenticator = jira.BasicAuth{
+		Username: vip.GetString("jira.username"),
+		Token:    vip.GetString("jira.api_token"),
+	}
+	if pat := vip.GetString("jira.personal_access_token"); pat != "" {
+		auth = jira.BearerAuth{Token: pat}
+	}
+
+	opts := []jira.Option{
+		jira.WithFlavour(flavour),
+		jira.WithSince(sinceTime),
+		jira.WithMaxConcurrency(vip.GetInt("jira.max_concurrency")),
+		jira.WithCustomFields(vip.GetStringMapString("fields")),
+	}
+	cacheOpt, err := cacheOption(vip)
+	if err != nil {
+		return nil, err
+	}
+	if cacheOpt != nil {
+		opts = append(opts, cacheOpt)
+	}
+
+	jiraClient, err := jira.NewClient(vip.GetString("jira.url"), auth, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid jira Client: %v", err)
+	}
+
+	return jiraClient, nil
+}
+
+// run executes the main logic of the command.
+func runRoot(ctx context.Context, vip *viper.Viper, args []string) error {
 	sinceTime, err := sinceflag.ParseSince(vip.GetString("since"))
 	if err != nil {
 		return fmt.Errorf("invalid --since value: %w", err)
 	}
 
-	for issue, err := range getTopIssues(jiraClient, vip.GetString("group"), args...) {
+	var source backend
+	if path := vip.GetString("from-snapshot"); path != "" {
+		snapshot, err := jira.LoadSnapshot(path)
+		if err != nil {
+			return err
+		}
+		if sinceTime.Before(snapshot.Since) {
+			slog.Warn(fmt.Sprintf("the snapshot only has events since %s: older ones are missing", snapshot.Since.Format(time.DateTime)))
+		}
+		source = snapshot
+	} else {
+		source, err = newJiraClient(vip, sinceTime)
 		if err != nil {
 			return err
 		}
+	}
 
-		if issue.Embedder() {
-			// Don't show comments on top issues which are embedder, as they can be generated from children work.
-			issue.Comments = nil
+	return summarize(ctx, source, vip, sinceTime, args)
+}
+
+// changeFilter returns the field changes counting as activity and shown in reports, from the configuration.
+func changeFilter(vip *viper.Viper) jira.ChangeFilter {
+	filter := jira.ChangeFilter{
+		Activity: jira.DefaultChangeFields,
+		Shown:    jira.DefaultChangeFields,
+	}
+	if vip.IsSet("changes.activity") {
+		filter.Activity = vip.GetStringSlice("changes.activity")
+	}
+	if vip.IsSet("changes.shown") {
+		filter.Shown = vip.GetStringSlice("changes.shown")
+	}
+	return filter
+}
+
+// summarize prints or posts the summaries of the top issues from the backend, with events since sinceTime.
+// The timeout only applies to fetching the issues, not to editing and posting summaries.
+func summarize(ctx context.Context, source backend, vip *viper.Viper, sinceTime time.Time, args []string) error {
+	topIssues := fetchWithTimeout(ctx, vip, func(ctx context.Context) iter.Seq2[jira.Issue, error] {
+		return getTopIssues(ctx, source, vip.GetString("group"), vip.GetBool("strict"), args...)
+	})
+	for issue, err := range topIssues {
+		if err != nil {
+			return err
 		}
 
-		if !issue.KeptRecentEvents(sinceTime) {
+		summary, ok := topIssueReport(issue, sinceTime, changeFilter(vip))
+		if !ok {
 			continue
 		}
 
-		summary := report(issue)
-
 		switch {
 		case vip.GetBool("no-post"):
 			printTopSummary(summary)
 		default:
-			if err := editSummaryAndPost(jiraClient, issue, summary); err != nil {
-				return fmt.Errorf("error posting new summary: %v", err)
+			if err := editSummaryAndPost(ctx, source, issue, summary); err != nil {
+				return fmt.Errorf("error posting new summary: %w", err)
 			}
 
 		}
@@ -172,3 +493,82 @@ func runRoot(vip *viper.Viper, args []string) error {
 
 	return nil
 }
+
+// runSnapshot fetches the top issues, without grouping nor filtering them, and writes them to output.
+func runSnapshot(ctx context.Context, vip *viper.Viper, output string, args []string) (err error) {
+	ctx, cancel := withTimeout(ctx, vip)
+	defer cancel()
+
+	sinceTime, err := sinceflag.ParseSince(vip.GetString("since"))
+	if err != nil {
+		return fmt.Errorf("invalid --since value: %w", err)
+	}
+
+	jiraClient, err := newJiraClient(vip, sinceTime)
+	if err != nil {
+		return err
+	}
+
+	var issues []jira.Issue
+	for issue, err := range getTopIssues(ctx, jiraClient, "top", vip.GetBool("strict"), args...) {
+		if err != nil {
+			return err
+		}
+		issues = append(issues, issue)
+	}
+	snapshot := jira.NewSnapshot(sinceTime, issues)
+
+	if output == "-" {
+		return snapshot.Write(os.Stdout)
+	}
+
+	f, err := os.Create(output)
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot file: %v", err)
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil && cerr != nil {
+			err = fmt.Errorf("failed to write snapshot file: %v", cerr)
+		}
+	}()
+
+	if err := snapshot.Write(f); err != nil {
+		return fmt.Errorf("failed to write snapshot file: %v", err)
+	}
+
+	slog.Info(fmt.Sprintf("saved %d issues to %s", len(issues), output))
+
+	return nil
+}
+
+// runFields prints the ID and name of all issue fields of the Jira instance, custom fields last.
+func runFields(ctx context.Context, vip *viper.Viper) error {
+	ctx, cancel := withTimeout(ctx, vip)
+	defer cancel()
+
+	jiraClient, err := newJiraClient(vip, time.Time{})
+	if err != nil {
+		return err
+	}
+
+	fields, err := jiraClient.GetFields(ctx)
+	if err != nil {
+		return err
+	}
+	slices.SortFunc(fields, func(a, b jira.Field) int {
+		if a.Custom != b.Custom {
+			if a.Custom {
+				return 1
+			}
+			return -1
+		}
+		return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME")
+	for _, f := range fields {
+		fmt.Fprintf(w, "%s\t%s\n", f.ID, f.Name)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,461 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/canonical/jira-summarizer/internal/jira"
+	"github.com/canonical/jira-summarizer/internal/jira/jiratest"
+	"github.com/canonical/jira-summarizer/internal/testutils"
+	"github.com/spf13/viper"
+)
+
+// day is the reference time of the test issues, after the --since date used in tests.
+var day = testutils.Day
+
+// testIssues is the fake Jira content of the CLI tests.
+var testIssues = []jiratest.Issue{
+	{Key: "OBJ-1", IssueType: "Objective", Summary: "Objective", Created: day},
+	{Key: "EPIC-1", IssueType: "Epic", Summary: "Assigned epic", Description: "Epic description", Status: "In Progress",
+		Assignee: jiratest.CurrentUser, Parent: "OBJ-1", Created: day, CustomFields: map[string]any{"customfield_10016": 5.0}},
+	{Key: "EPIC-2", IssueType: "Epic", Summary: "Other epic", Description: "Other description", Status: "In Progress",
+		Parent: "OBJ-1", Created: day},
+	{Key: "TASK-1", IssueType: "Task", Summary: "Task of assigned epic", Parent: "EPIC-1", Created: day,
+		Comments: []jiratest.Comment{{Author: "Alice", Created: day, Body: "Work in progress"}}},
+}
+
+// Tests are not parallel as they capture the standard output.
+func TestRunRoot(t *testing.T) {
+	tests := map[string]struct {
+		args   []string
+		group  string
+		strict bool
+
+		want    []string
+		notWant []string
+		wantErr bool
+	}{
+		"Summarizes assigned epics": {
+			want:    []string{"Title: Assigned epic", "story_points: 5", "|- Task: TASK-1", "Alice (10/06/2025 10:00): Work in progress"},
+			notWant: []string{"Other epic"},
+		},
+		"Summarizes given issues":                {args: []string{"EPIC-2"}, want: []string{"Title: Other epic"}, notWant: []string{"Assigned epic"}},
+		"Summarizes children of given issues":    {args: []string{"OBJ-1"}, group: "children", want: []string{"Title: Assigned epic", "Title: Other epic"}, notWant: []string{"Title: Objective"}},
+		"Merges given issues in a virtual issue": {args: []string{"EPIC-1", "EPIC-2"}, group: "merge", want: []string{"< This top issue is tracking all children work here. >", "|- Task: EPIC-2"}},
+		"Skips issues which can't be retrieved":  {args: []string{"EPIC-404", "EPIC-2"}, want: []string{"Title: Other epic"}},
+
+		"Error on issues which can't be retrieved in strict mode": {args: []string{"EPIC-404", "EPIC-2"}, strict: true, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			srv := jiratest.NewServer(t, testIssues)
+			vip := newTestConfig(srv.URL)
+			if tc.group != "" {
+				vip.Set("group", tc.group)
+			}
+			vip.Set("strict", tc.strict)
+
+			var err error
+			out := captureStdout(t, func() {
+				err = runRoot(context.Background(), vip, tc.args)
+			})
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("runRoot should have failed but didn't")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("runRoot returned an unexpected error: %v", err)
+			}
+
+			for _, w := range tc.want {
+				if !strings.Contains(out, w) {
+					t.Errorf("Output should contain %q but doesn't:\n%s", w, out)
+				}
+			}
+			for _, w := range tc.notWant {
+				if strings.Contains(out, w) {
+					t.Errorf("Output should not contain %q but does:\n%s", w, out)
+				}
+			}
+		})
+	}
+}
+
+func TestSnapshotReplay(t *testing.T) {
+	srv := jiratest.NewServer(t, testIssues)
+	vip := newTestConfig(srv.URL)
+
+	var err error
+	want := captureStdout(t, func() {
+		err = runRoot(context.Background(), vip, nil)
+	})
+	if err != nil {
+		t.Fatalf("Setup: runRoot returned an unexpected error: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	if err := runSnapshot(context.Background(), vip, path, nil); err != nil {
+		t.Fatalf("runSnapshot returned an unexpected error: %v", err)
+	}
+
+	srv.Close()
+	vip.Set("from-snapshot", path)
+	got := captureStdout(t, func() {
+		err = runRoot(context.Background(), vip, nil)
+	})
+	if err != nil {
+		t.Fatalf("runRoot from snapshot returned an unexpected error: %v", err)
+	}
+
+	if got != want {
+		t.Errorf("Summary from snapshot differs from the live one.\nGot:\n%s\nWant:\n%s", got, want)
+	}
+}
+
+func TestFields(t *testing.T) {
+	srv := jiratest.NewServer(t, nil, jiratest.WithCustomFields(map[string]string{"customfield_10016": "Story Points"}))
+	vip := newTestConfig(srv.URL)
+
+	var err error
+	out := captureStdout(t, func() {
+		err = runFields(context.Background(), vip)
+	})
+	if err != nil {
+		t.Fatalf("runFields returned an unexpected error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) < 3 || !strings.HasPrefix(lines[0], "ID") {
+		t.Fatalf("Output should be a table of fields with a header, got:\n%s", out)
+	}
+	if got := strings.Fields(lines[len(lines)-1]); !slices.Equal(got, []string{"customfield_10016", "Story", "Points"}) {
+		t.Errorf("Custom fields should be listed last, got:\n%s", out)
+	}
+}
+
+// newTestConfig returns the configuration to summarize issues of the Jira server at url without posting.
+func newTestConfig(url string) *viper.Viper {
+	vip := viper.New()
+	vip.Set("jira.url", url)
+	vip.Set("jira.username", jiratest.Username)
+	vip.Set("jira.api_token", jiratest.Token)
+	vip.Set("since", "2025-06-01")
+	vip.Set("group", "top")
+	vip.Set("no-post", true)
+	vip.Set("fields", map[string]string{"story_points": "customfield_10016"})
+	return vip
+}
+
+// captureStdout returns what f writes on the standard output.
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Setup: failed to create pipe: %v", err)
+	}
+	orig := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = orig }()
+
+	out := make(chan string)
+	go func() {
+		b, _ := io.ReadAll(r)
+		out <- string(b)
+	}()
+
+	f()
+	w.Close()
+
+	return <-out
+}
+
+func TestApplyProfile(t *testing.T) {
+	config := `
+jira:
+  url: https://top.example.com
+  username: top-user
+  api_token: top-token
+since: 2w
+profiles:
+  settings:
+    since: 1w
+    group: children
+  other-site:
+    jira:
+      url: https://other.example.com
+  other-site-with-credentials:
+    jira:
+      url: https://other.example.com
+      personal_access_token: other-token
+  same-site-other-user:
+    jira:
+      username: other-user
+      api_token: other-token
+`
+
+	tests := map[string]struct {
+		profile string
+
+		want    map[string]string
+		wantErr bool
+	}{
+		"No profile keeps the top level configuration": {
+			want: map[string]string{"jira.url": "https://top.example.com", "jira.username": "top-user", "jira.api_token": "top-token", "since": "2w"},
+		},
+		"Profile settings override the top level ones": {
+			profile: "settings",
+			want:    map[string]string{"jira.url": "https://top.example.com", "jira.username": "top-user", "jira.api_token": "top-token", "since": "1w", "group": "children"},
+		},
+		"Profile with another url doesn't inherit credentials": {
+			profile: "other-site",
+			want:    map[string]string{"jira.url": "https://other.example.com", "jira.username": "", "jira.api_token": "", "since": "2w"},
+		},
+		"Profile with another url uses its own credentials": {
+			profile: "other-site-with-credentials",
+			want:    map[string]string{"jira.url": "https://other.example.com", "jira.username": "", "jira.api_token": "", "jira.personal_access_token": "other-token"},
+		},
+		"Profile without url can change credentials": {
+			profile: "same-site-other-user",
+			want:    map[string]string{"jira.url": "https://top.example.com", "jira.username": "other-user", "jira.api_token": "other-token"},
+		},
+
+		"Error on unknown profile": {profile: "unknown", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			vip := viper.New()
+			vip.SetConfigType("yaml")
+			if err := vip.ReadConfig(strings.NewReader(config)); err != nil {
+				t.Fatalf("Setup: failed to read configuration: %v", err)
+			}
+
+			err := applyProfile(vip, tc.profile)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("applyProfile should have returned an error but didn't")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applyProfile returned an unexpected error: %v", err)
+			}
+
+			for key, want := range tc.want {
+				if got := vip.GetString(key); got != want {
+					t.Errorf("%s = %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	since := day.AddDate(0, 0, -7)
+	old := day.AddDate(0, -1, 0)
+
+	tests := map[string]struct {
+		issues []jira.Issue
+
+		want    []string
+		notWant []string
+	}{
+		"Summarizes top issues with recent events": {
+			issues: []jira.Issue{testutils.NewIssue("EPIC-1", "Epic")},
+			want:   []string{"Title: Summary of EPIC-1", "Comment on EPIC-1"},
+		},
+		"Hides comments of embedder top issues": {
+			issues:  []jira.Issue{testutils.WithChildren(testutils.NewIssue("EPIC-1", "Epic"), testutils.NewIssue("TASK-1", "Task"))},
+			want:    []string{"Comment on TASK-1"},
+			notWant: []string{"Comment on EPIC-1"},
+		},
+		"Skips top issues without recent events": {
+			issues: []jira.Issue{
+				{Key: "EPIC-1", IssueType: "Epic", Summary: "Idle epic", Created: old},
+				testutils.NewIssue("EPIC-2", "Epic"),
+			},
+			want:    []string{"Title: Summary of EPIC-2"},
+			notWant: []string{"Idle epic"},
+		},
+		"Summarizes top issues with recent changes of activity fields": {
+			issues: []jira.Issue{
+				{Key: "EPIC-1", IssueType: "Epic", Summary: "Reprioritized epic", Created: old,
+					Changes: []jira.Change{{Field: "priority", From: "Medium", To: "High", Who: "Bob", When: day}}},
+				{Key: "EPIC-2", IssueType: "Epic", Summary: "Ranked epic", Created: old,
+					Changes: []jira.Change{{Field: "Rank", To: "Ranked higher", Who: "Bob", When: day}}},
+			},
+			want:    []string{"Title: Reprioritized epic", "priority changed from Medium to High"},
+			notWant: []string{"Ranked epic"},
+		},
+		"Summarizes top issues with recent worklogs": {
+			issues: []jira.Issue{
+				{Key: "EPIC-1", IssueType: "Epic", Summary: "Epic with logged time", Created: old,
+					Worklogs: []jira.Worklog{{Who: "Bob", Started: day, TimeSpent: time.Hour}}},
+			},
+			want: []string{"Title: Epic with logged time", "Time logged per person: Bob 1h"},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			b := &fakeBackend{issues: tc.issues}
+			vip := newTestConfig("")
+
+			var err error
+			out := captureStdout(t, func() {
+				err = summarize(context.Background(), b, vip, since, nil)
+			})
+			if err != nil {
+				t.Fatalf("summarize returned an unexpected error: %v", err)
+			}
+
+			for _, w := range tc.want {
+				if !strings.Contains(out, w) {
+					t.Errorf("Output should contain %q but doesn't:\n%s", w, out)
+				}
+			}
+			for _, w := range tc.notWant {
+				if strings.Contains(out, w) {
+					t.Errorf("Output should not contain %q but does:\n%s", w, out)
+				}
+			}
+			if len(b.comments) > 0 {
+				t.Errorf("No comment should be posted without posting, got %v", b.comments)
+			}
+		})
+	}
+}
+
+func TestRunRootEditingIsNotTimedOut(t *testing.T) {
+	// The fake editor prepends the summary after the fetch timeout.
+	writeFakeEditor(t, 200*time.Millisecond)
+
+	srv := jiratest.NewServer(t, testIssues)
+	vip := newTestConfig(srv.URL)
+	vip.Set("no-post", false)
+	vip.Set("timeout", 100*time.Millisecond)
+
+	var err error
+	captureStdout(t, func() {
+		err = runRoot(context.Background(), vip, nil)
+	})
+	if err != nil {
+		t.Fatalf("runRoot returned an unexpected error: %v", err)
+	}
+	epic, _ := srv.Issue("EPIC-1")
+	if last := epic.Comments[len(epic.Comments)-1]; last.Body != "Edited summary" {
+		t.Errorf("Last comment on EPIC-1 = %q, want the edited summary", last.Body)
+	}
+}
+
+func TestSummarizeTimeout(t *testing.T) {
+	since := day.AddDate(0, 0, -7)
+	writeFakeEditor(t, 200*time.Millisecond)
+
+	tests := map[string]struct {
+		delay time.Duration
+
+		wantErr    error
+		wantEvents []string
+	}{
+		"Editing is not timed out and summaries are posted as issues are fetched": {
+			wantEvents: []string{"fetched EPIC-1", "posted EPIC-1", "fetched EPIC-2", "posted EPIC-2"},
+		},
+		"Error when fetching takes longer than the timeout": {
+			delay:   150 * time.Millisecond,
+			wantErr: context.DeadlineExceeded,
+			// Only the time spent fetching counts, not the one spent editing.
+			wantEvents: []string{"fetched EPIC-1", "posted EPIC-1"},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			b := &fakeBackend{issues: []jira.Issue{testutils.NewIssue("EPIC-1", "Epic"), testutils.NewIssue("EPIC-2", "Epic")}, delay: tc.delay}
+			vip := newTestConfig("")
+			vip.Set("no-post", false)
+			vip.Set("timeout", 250*time.Millisecond)
+
+			err := summarize(context.Background(), b, vip, since, nil)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("summarize returned %v, want %v", err, tc.wantErr)
+			}
+			if !slices.Equal(b.events, tc.wantEvents) {
+				t.Errorf("Events = %q, want %q", b.events, tc.wantEvents)
+			}
+		})
+	}
+}
+
+// fakeBackend serves top issues from memory and records posted comments.
+// Each top issue takes delay to be fetched, and fetches and posts are recorded in events.
+type fakeBackend struct {
+	issues   []jira.Issue
+	comments map[string][]string
+	delay    time.Duration
+	events   []string
+}
+
+func (b *fakeBackend) GetMyAssignedEpics(ctx context.Context) iter.Seq2[jira.Issue, error] {
+	return func(yield func(jira.Issue, error) bool) {
+		for _, issue := range b.issues {
+			select {
+			case <-time.After(b.delay):
+			case <-ctx.Done():
+				yield(jira.Issue{}, ctx.Err())
+				return
+			}
+			b.events = append(b.events, "fetched "+issue.Key)
+			if !yield(issue, nil) {
+				return
+			}
+		}
+	}
+}
+
+func (b *fakeBackend) GetIssuesByKeys(ctx context.Context, keys ...string) iter.Seq2[jira.Issue, error] {
+	return func(yield func(jira.Issue, error) bool) {
+		for _, key := range keys {
+			idx := slices.IndexFunc(b.issues, func(i jira.Issue) bool { return i.Key == key })
+			var more bool
+			if idx < 0 {
+				more = yield(jira.Issue{}, &jira.KeyError{Key: key, Err: jira.ErrNotFound})
+			} else {
+				more = yield(b.issues[idx], nil)
+			}
+			if !more {
+				return
+			}
+		}
+	}
+}
+
+func (b *fakeBackend) AddComment(ctx context.Context, key, commentBody string) error {
+	if b.comments == nil {
+		b.comments = make(map[string][]string)
+	}
+	b.comments[key] = append(b.comments[key], commentBody)
+	b.events = append(b.events, "posted "+key)
+	return nil
+}
+
+// writeFakeEditor installs an editor which prepends "Edited summary" to the file after delay.
+func writeFakeEditor(t *testing.T, delay time.Duration) {
+	t.Helper()
+
+	dir := t.TempDir()
+	editor := fmt.Sprintf("#!/bin/sh\nsleep %g\n{ echo 'Edited summary'; cat \"$1\"; } > \"$1.new\" && mv \"$1.new\" \"$1\"\n", delay.Seconds())
+	if err := os.WriteFile(filepath.Join(dir, "sensible-editor"), []byte(editor), 0700); err != nil {
+		t.Fatalf("Setup: failed to write fake editor: %v", err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}